@@ -0,0 +1,5 @@
+package admin
+
+type Configuration struct {
+	BindAddr string `yaml:"bind-addr"`
+}
@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const contentTypeYAML = "application/x-yaml"
+const contentTypeJSON = "application/json"
+
+// writeValue will write v to the response as YAML, unless the client accepts
+// JSON, in that case v is written as JSON using the same field names as the YAML.
+func writeValue(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	contentType := contentTypeYAML
+	if strings.Contains(r.Header.Get("Accept"), contentTypeJSON) {
+		var generic interface{}
+		if err := yaml.Unmarshal(data, &generic); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data, err = json.Marshal(jsonCompatible(generic))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		contentType = contentTypeJSON
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// readValue decodes the request body into v, as JSON is valid YAML both may be
+// used.
+func readValue(r *http.Request, v interface{}) error {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+// jsonCompatible converts the map[interface{}]interface{} produced by the
+// yaml decoder into map[string]interface{} that can be encoded as JSON.
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[fmt.Sprintf("%v", k)] = jsonCompatible(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range t {
+			t[i] = jsonCompatible(e)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// conversationField names the i'th conversation read, in validation errors.
+func conversationField(i int, name string) string {
+	if name == "" {
+		return fmt.Sprintf("conversations[%d]", i)
+	}
+	return fmt.Sprintf("conversations[%s]", name)
+}
+
+// decodeList will decode the generic yaml value v into the slice pointed to by
+// target, if v is not a list it is decoded as the only element of the slice.
+func decodeList(v interface{}, target interface{}) error {
+	if _, isList := v.([]interface{}); !isList {
+		v = []interface{}{v}
+	}
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, target)
+}
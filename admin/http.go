@@ -0,0 +1,363 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/thorsager/mockdev/mockhttp"
+	"github.com/thorsager/mockdev/validation"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (s *Server) serveHttp(w http.ResponseWriter, r *http.Request, segs []string) {
+	if len(segs) == 0 {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		writeValue(w, r, http.StatusOK, s.Registry.HttpNames())
+		return
+	}
+	h, found := s.Registry.Http(segs[0])
+	if !found {
+		serviceNotFound(w, "http", segs[0])
+		return
+	}
+	switch {
+	case len(segs) == 2 && segs[1] == "conversations":
+		s.serveHttpConversations(w, r, h)
+	case len(segs) == 3 && segs[1] == "conversations":
+		s.serveHttpConversation(w, r, h, segs[2])
+	case len(segs) == 2 && segs[1] == "scenarios":
+		switch r.Method {
+		case http.MethodGet:
+			writeValue(w, r, http.StatusOK, h.ScenarioStates())
+		case http.MethodDelete:
+			h.ResetScenarios()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		}
+	case len(segs) == 2 && segs[1] == "sequences":
+		switch r.Method {
+		case http.MethodGet:
+			writeValue(w, r, http.StatusOK, h.ResponseSequences())
+		case http.MethodDelete:
+			h.ResetResponseSequences()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		}
+	case len(segs) == 3 && segs[1] == "sequences":
+		if r.Method != http.MethodDelete {
+			methodNotAllowed(w, http.MethodDelete)
+			return
+		}
+		h.ResetResponseSequences(segs[2])
+		w.WriteHeader(http.StatusNoContent)
+	case len(segs) == 2 && segs[1] == "sessions":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		ids, err := h.SessionLogs()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeValue(w, r, http.StatusOK, ids)
+	case len(segs) == 3 && segs[1] == "sessions":
+		serveSessionLog(w, r, segs[2], h.ReadSessionLog)
+	case len(segs) == 4 && segs[1] == "sessions" && segs[3] == "har":
+		s.serveHttpHAR(w, r, h, []string{segs[2]})
+	case len(segs) == 2 && segs[1] == "journal":
+		switch r.Method {
+		case http.MethodGet:
+			writeValue(w, r, http.StatusOK, h.Journal())
+		case http.MethodDelete:
+			h.ClearJournal()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		}
+	case len(segs) == 3 && segs[1] == "journal" && segs[2] == "har":
+		s.serveHttpHAR(w, r, h, r.URL.Query()["session"])
+	case len(segs) == 3 && segs[1] == "journal" && segs[2] == "find":
+		s.serveHttpJournalFind(w, r, h)
+	case len(segs) == 3 && segs[1] == "journal" && segs[2] == "verify":
+		s.serveHttpJournalVerify(w, r, h)
+	case len(segs) == 2 && segs[1] == "explain":
+		s.serveHttpExplain(w, r, h)
+	case len(segs) == 2 && segs[1] == "reset":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		h.ResetSessions()
+		h.ResetScenarios()
+		h.ResetResponseSequences()
+		h.ClearJournal()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveHttpConversations(w http.ResponseWriter, r *http.Request, h *mockhttp.ConversationsHandler) {
+	switch r.Method {
+	case http.MethodGet:
+		writeValue(w, r, http.StatusOK, h.GetConversations())
+	case http.MethodPost, http.MethodPut:
+		conversations, err := readHttpConversations(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPut {
+			h.SetConversations(conversations)
+		} else {
+			for _, c := range conversations {
+				h.PutConversation(c)
+			}
+		}
+		s.Log.Infof("http conversations updated (%d)", len(conversations))
+		writeValue(w, r, http.StatusOK, h.GetConversations())
+	case http.MethodDelete:
+		h.SetConversations(nil)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
+	}
+}
+
+func (s *Server) serveHttpConversation(w http.ResponseWriter, r *http.Request, h *mockhttp.ConversationsHandler, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		c, found := h.GetConversation(name)
+		if !found {
+			http.Error(w, fmt.Sprintf("conversation '%s' not found", name), http.StatusNotFound)
+			return
+		}
+		writeValue(w, r, http.StatusOK, c)
+	case http.MethodPut:
+		var c mockhttp.Conversation
+		if err := readValue(r, &c); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.Name = name
+		if errs := c.Validate(); len(errs) > 0 {
+			http.Error(w, errs.Error(), http.StatusBadRequest)
+			return
+		}
+		status := http.StatusCreated
+		if h.PutConversation(c) {
+			status = http.StatusOK
+		}
+		s.Log.Infof("http conversation '%s' updated", name)
+		writeValue(w, r, status, c)
+	case http.MethodDelete:
+		if !h.DeleteConversation(name) {
+			http.Error(w, fmt.Sprintf("conversation '%s' not found", name), http.StatusNotFound)
+			return
+		}
+		s.Log.Infof("http conversation '%s' deleted", name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// readHttpConversations reads either a single conversation or a list of
+// conversations from the request body, the conversations are validated, as
+// when read from a conversation-file.
+func readHttpConversations(r *http.Request) ([]mockhttp.Conversation, error) {
+	var raw interface{}
+	if err := readValue(r, &raw); err != nil {
+		return nil, err
+	}
+	var conversations []mockhttp.Conversation
+	if err := decodeList(raw, &conversations); err != nil {
+		return nil, err
+	}
+	var errs validation.Errors
+	for i, c := range conversations {
+		errs = append(errs, c.Validate().Within(conversationField(i, c.Name), "")...)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return conversations, nil
+}
+
+type journalResult struct {
+	Count    int                     `yaml:"count"`
+	Requests []mockhttp.JournalEntry `yaml:"requests"`
+}
+
+type journalVerification struct {
+	mockhttp.JournalQuery `yaml:",inline"`
+	Count                 *int `yaml:"count,omitempty"`
+	MinCount              *int `yaml:"min-count,omitempty"`
+	MaxCount              *int `yaml:"max-count,omitempty"`
+}
+
+type verificationResult struct {
+	Verified bool                    `yaml:"verified"`
+	Count    int                     `yaml:"count"`
+	Expected string                  `yaml:"expected"`
+	Requests []mockhttp.JournalEntry `yaml:"requests"`
+}
+
+func (s *Server) serveHttpJournalFind(w http.ResponseWriter, r *http.Request, h *mockhttp.ConversationsHandler) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	var q mockhttp.JournalQuery
+	if err := readValue(r, &q); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	found, err := h.FindRequests(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeValue(w, r, http.StatusOK, journalResult{Count: len(found), Requests: found})
+}
+
+// serveHttpJournalVerify counts the requests selected by the query, and
+// responds 200 if the count is as expected, or 417 if it is not. If no count
+// is given at least one request is expected.
+func (s *Server) serveHttpJournalVerify(w http.ResponseWriter, r *http.Request, h *mockhttp.ConversationsHandler) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	var v journalVerification
+	if err := readValue(r, &v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	found, err := h.FindRequests(v.JournalQuery)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result := verificationResult{Count: len(found), Requests: found, Verified: true}
+	switch {
+	case v.Count != nil:
+		result.Expected = fmt.Sprintf("== %d", *v.Count)
+		result.Verified = len(found) == *v.Count
+	case v.MinCount != nil || v.MaxCount != nil:
+		if v.MinCount != nil {
+			result.Expected = fmt.Sprintf(">= %d", *v.MinCount)
+			result.Verified = len(found) >= *v.MinCount
+		}
+		if v.MaxCount != nil {
+			if result.Expected != "" {
+				result.Expected += " && "
+			}
+			result.Expected += fmt.Sprintf("<= %d", *v.MaxCount)
+			result.Verified = result.Verified && len(found) <= *v.MaxCount
+		}
+	default:
+		result.Expected = ">= 1"
+		result.Verified = len(found) >= 1
+	}
+	status := http.StatusOK
+	if !result.Verified {
+		status = http.StatusExpectationFailed
+	}
+	writeValue(w, r, status, result)
+}
+
+// explainRequest is a request to explain, the path may include a query, and
+// the Host header selects the virtual host.
+type explainRequest struct {
+	Method  string            `yaml:"method,omitempty"` // default GET
+	Path    string            `yaml:"path"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
+	Closest int               `yaml:"closest,omitempty"` // default mockhttp.DefaultClosest
+}
+
+// serveHttpExplain explains how the request described by the body would be
+// matched, without serving it.
+func (s *Server) serveHttpExplain(w http.ResponseWriter, r *http.Request, h *mockhttp.ConversationsHandler) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	var e explainRequest
+	if err := readValue(r, &e); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if e.Method == "" {
+		e.Method = http.MethodGet
+	}
+	if !strings.HasPrefix(e.Path, "/") {
+		http.Error(w, fmt.Sprintf("invalid path '%s', must start with '/'", e.Path), http.StatusBadRequest)
+		return
+	}
+	req, err := http.NewRequest(e.Method, "http://"+h.BindAddress+e.Path, strings.NewReader(e.Body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for name, value := range e.Headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	explanation, err := h.Explain(req, e.Closest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeValue(w, r, http.StatusOK, explanation)
+}
+
+// serveHttpHAR writes the journal as an HTTP Archive, only the requests of the
+// sessions with the passed ids, if any.
+func (s *Server) serveHttpHAR(w http.ResponseWriter, r *http.Request, h *mockhttp.ConversationsHandler, sessions []string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	entries := h.Journal()
+	if len(sessions) > 0 {
+		ids := make(map[int]bool)
+		for _, raw := range sessions {
+			id, err := strconv.Atoi(raw)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid session id '%s'", raw), http.StatusBadRequest)
+				return
+			}
+			ids[id] = true
+		}
+		var selected []mockhttp.JournalEntry
+		for _, e := range entries {
+			if ids[e.SessionId] {
+				selected = append(selected, e)
+			}
+		}
+		if len(selected) == 0 {
+			http.Error(w, fmt.Sprintf("session(s) %s not found in journal", strings.Join(sessions, ", ")), http.StatusNotFound)
+			return
+		}
+		entries = selected
+	}
+	data, err := json.MarshalIndent(h.JournalHAR(entries), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	_, _ = w.Write(data)
+}
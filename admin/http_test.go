@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"github.com/thorsager/mockdev/mockhttp"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestHttpHandler(s *Server) *mockhttp.ConversationsHandler {
+	h := &mockhttp.ConversationsHandler{Log: s.Log, BindAddress: "127.0.0.1:8080"}
+	h.SetConversations(nil)
+	return h
+}
+
+func TestHttpConversationsValidated(t *testing.T) {
+	s, reg := newTestServer()
+	h := newTestHttpHandler(s)
+	reg.RegisterHttp("default", h)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		want   string // in the response
+	}{
+		{http.MethodPut, "/api/http/default/conversations/drip",
+			`{"response":{"status-code":200,"fault":{"type":"drip","bytes-per-second":0}}}`,
+			http.StatusBadRequest, "response.fault.bytes-per-second: must be positive"},
+		{http.MethodPost, "/api/http/default/conversations",
+			`[{"name":"weight","responses":[{"status-code":200,"weight":-1}]}]`,
+			http.StatusBadRequest, "conversations[weight].responses[0].weight: must not be negative"},
+		{http.MethodPut, "/api/http/default/conversations",
+			`{"name":"ws","websocket":{"messages":[{"matcher":"(a","reply":"b"}]}}`,
+			http.StatusBadRequest, "conversations[ws].websocket.messages[0].matcher"},
+		{http.MethodPut, "/api/http/default/conversations/path",
+			`{"request":{"url-matcher":{"path":"^/a("}},"response":{"status-code":200}}`,
+			http.StatusBadRequest, "request.url-matcher.path"},
+		{http.MethodPost, "/api/http/default/conversations",
+			`[{"name":"ok","response":{"status-code":200}},{"name":"break","break-on":"always","response":{"status-code":200}}]`,
+			http.StatusBadRequest, "conversations[break].break-on"},
+		{http.MethodPut, "/api/http/default/conversations",
+			`{"response":{"status-code":200}}`,
+			http.StatusBadRequest, "conversations[0].name: missing name"},
+		{http.MethodPut, "/api/http/default/conversations/ok",
+			`{"request":{"url-matcher":{"path":"^/ok$"}},"response":{"status-code":200}}`,
+			http.StatusCreated, "ok"},
+	}
+	for _, tt := range tests {
+		w := do(s, tt.method, tt.path, tt.body)
+		expectStatus(t, w, tt.status)
+		if !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s %s = %s, want %s", tt.method, tt.path, w.Body.String(), tt.want)
+		}
+	}
+	if got := len(h.GetConversations()); got != 1 {
+		t.Errorf("conversations = %d, want 1", got)
+	}
+}
+
+func TestHttpScenariosReset(t *testing.T) {
+	s, reg := newTestServer()
+	h := newTestHttpHandler(s)
+	h.SetConversations([]mockhttp.Conversation{
+		{Name: "apply", Scenario: "config", NewState: "applied", Response: mockhttp.Response{StatusCode: 200}},
+	})
+	reg.RegisterHttp("default", h)
+
+	for _, reset := range []struct{ method, path string }{
+		{http.MethodDelete, "/api/http/default/scenarios"},
+		{http.MethodPost, "/api/http/default/reset"},
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		w := do(s, http.MethodGet, "/api/http/default/scenarios", "")
+		expectStatus(t, w, http.StatusOK)
+		if got := strings.TrimSpace(w.Body.String()); got != "config: applied" {
+			t.Errorf("scenarios = %s, want config applied", got)
+		}
+
+		expectStatus(t, do(s, reset.method, reset.path, ""), http.StatusNoContent)
+		w = do(s, http.MethodGet, "/api/http/default/scenarios", "")
+		expectStatus(t, w, http.StatusOK)
+		if got := strings.TrimSpace(w.Body.String()); got != "{}" {
+			t.Errorf("scenarios after %s %s = %s, want none", reset.method, reset.path, got)
+		}
+	}
+}
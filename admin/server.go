@@ -0,0 +1,115 @@
+package admin
+
+import (
+	"fmt"
+	"github.com/thorsager/mockdev/logging"
+	"github.com/thorsager/mockdev/registry"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const apiPrefix = "api"
+
+// Server is the admin REST API of mockdevd, it gives runtime access to the
+// conversations and OIDs of all services in the registry. All resources are found
+// below /api/<type>/<service-name>, where type is one of http, ssh or snmp.
+type Server struct {
+	Log      logging.Logger
+	Registry *registry.Registry
+}
+
+type serviceList struct {
+	Http []string `yaml:"http"`
+	Ssh  []string `yaml:"ssh"`
+	Snmp []string `yaml:"snmp"`
+}
+
+func NewServer(reg *registry.Registry, logger logging.Logger) *Server {
+	return &Server{Log: logger, Registry: reg}
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Log.Debugf("admin request %s %s", r.Method, r.URL)
+	segs, err := pathSegments(r.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(segs) == 0 || segs[0] != apiPrefix {
+		http.NotFound(w, r)
+		return
+	}
+	segs = segs[1:]
+	if len(segs) == 0 {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		writeValue(w, r, http.StatusOK, serviceList{
+			Http: s.Registry.HttpNames(),
+			Ssh:  s.Registry.SshNames(),
+			Snmp: s.Registry.SnmpNames(),
+		})
+		return
+	}
+	switch segs[0] {
+	case "http":
+		s.serveHttp(w, r, segs[1:])
+	case "ssh":
+		s.serveSsh(w, r, segs[1:])
+	case "snmp":
+		s.serveSnmp(w, r, segs[1:])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// pathSegments splits the path of u into its unescaped segments
+func pathSegments(u *url.URL) ([]string, error) {
+	var segs []string
+	for _, seg := range strings.Split(strings.Trim(u.EscapedPath(), "/"), "/") {
+		if seg == "" {
+			continue
+		}
+		unescaped, err := url.PathUnescape(seg)
+		if err != nil {
+			return nil, err
+		}
+		segs = append(segs, unescaped)
+	}
+	return segs, nil
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
+
+func serviceNotFound(w http.ResponseWriter, kind, name string) {
+	http.Error(w, fmt.Sprintf("%s service '%s' not found", kind, name), http.StatusNotFound)
+}
+
+func serveSessionLog(w http.ResponseWriter, r *http.Request, rawId string, reader func(int) ([]byte, error)) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	id, err := strconv.Atoi(rawId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid session id '%s'", rawId), http.StatusBadRequest)
+		return
+	}
+	data, err := reader(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/thorsager/mockdev/registry"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestServer() (*Server, *registry.Registry) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	reg := registry.New()
+	return NewServer(reg, logger), reg
+}
+
+// do serves a request to s, and returns the response.
+func do(s *Server, method string, path string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Errorf("status = %d, want %d: %s", w.Code, status, w.Body.String())
+	}
+}
@@ -0,0 +1,114 @@
+package admin
+
+import (
+	"fmt"
+	"github.com/thorsager/mockdev/mocksnmp"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+func (s *Server) serveSnmp(w http.ResponseWriter, r *http.Request, segs []string) {
+	if len(segs) == 0 {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		writeValue(w, r, http.StatusOK, s.Registry.SnmpNames())
+		return
+	}
+	a, found := s.Registry.Snmp(segs[0])
+	if !found {
+		serviceNotFound(w, "snmp", segs[0])
+		return
+	}
+	switch {
+	case len(segs) == 2 && segs[1] == "oids":
+		s.serveSnmpOIDs(w, r, a)
+	case len(segs) == 3 && segs[1] == "oids":
+		s.serveSnmpOID(w, r, a, segs[2])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveSnmpOIDs(w http.ResponseWriter, r *http.Request, a *mocksnmp.Agent) {
+	switch r.Method {
+	case http.MethodGet:
+		writeValue(w, r, http.StatusOK, a.OIDs())
+	case http.MethodPost, http.MethodPut:
+		oids, err := readSnapshotStrings(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPut {
+			err = a.SetOIDs(oids...)
+		} else {
+			err = a.PutOIDs(oids...)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.Log.Infof("snmp oids updated (%d)", len(oids))
+		writeValue(w, r, http.StatusOK, a.OIDs())
+	case http.MethodDelete:
+		if err := a.SetOIDs(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
+	}
+}
+
+func (s *Server) serveSnmpOID(w http.ResponseWriter, r *http.Request, a *mocksnmp.Agent, oid string) {
+	switch r.Method {
+	case http.MethodGet:
+		v, found := a.GetOID(oid)
+		if !found {
+			http.Error(w, fmt.Sprintf("oid '%s' not found", oid), http.StatusNotFound)
+			return
+		}
+		writeValue(w, r, http.StatusOK, v)
+	case http.MethodDelete:
+		deleted, err := a.DeleteOID(oid)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			http.Error(w, fmt.Sprintf("oid '%s' not found", oid), http.StatusNotFound)
+			return
+		}
+		s.Log.Infof("snmp oid '%s' deleted", oid)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+	}
+}
+
+// readSnapshotStrings reads OIDs in the snmp-snapshot format from the request
+// body, either as a list, or as plain text with one OID pr. line.
+func readSnapshotStrings(r *http.Request) ([]string, error) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	var oids []string
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "text/plain") {
+		if err := yaml.Unmarshal(data, &oids); err == nil {
+			return oids, nil
+		}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		oids = append(oids, strings.TrimSpace(line))
+	}
+	return oids, nil
+}
@@ -0,0 +1,139 @@
+package admin
+
+import (
+	"fmt"
+	"github.com/thorsager/mockdev/mockssh"
+	"github.com/thorsager/mockdev/validation"
+	"net/http"
+)
+
+func (s *Server) serveSsh(w http.ResponseWriter, r *http.Request, segs []string) {
+	if len(segs) == 0 {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		writeValue(w, r, http.StatusOK, s.Registry.SshNames())
+		return
+	}
+	h, found := s.Registry.Ssh(segs[0])
+	if !found {
+		serviceNotFound(w, "ssh", segs[0])
+		return
+	}
+	switch {
+	case len(segs) == 2 && segs[1] == "conversations":
+		s.serveSshConversations(w, r, h)
+	case len(segs) == 3 && segs[1] == "conversations":
+		s.serveSshConversation(w, r, h, segs[2])
+	case len(segs) == 2 && segs[1] == "sessions":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		ids, err := h.SessionLogs()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeValue(w, r, http.StatusOK, ids)
+	case len(segs) == 3 && segs[1] == "sessions":
+		serveSessionLog(w, r, segs[2], h.ReadSessionLog)
+	case len(segs) == 2 && segs[1] == "reset":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		h.ResetSessions()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveSshConversations(w http.ResponseWriter, r *http.Request, h *mockssh.Handler) {
+	switch r.Method {
+	case http.MethodGet:
+		writeValue(w, r, http.StatusOK, h.GetConversations())
+	case http.MethodPost, http.MethodPut:
+		conversations, err := readSshConversations(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPut {
+			h.SetConversations(conversations)
+		} else {
+			for _, c := range conversations {
+				h.PutConversation(c)
+			}
+		}
+		s.Log.Infof("ssh conversations updated (%d)", len(conversations))
+		writeValue(w, r, http.StatusOK, h.GetConversations())
+	case http.MethodDelete:
+		h.SetConversations(nil)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
+	}
+}
+
+func (s *Server) serveSshConversation(w http.ResponseWriter, r *http.Request, h *mockssh.Handler, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		c, found := h.GetConversation(name)
+		if !found {
+			http.Error(w, fmt.Sprintf("conversation '%s' not found", name), http.StatusNotFound)
+			return
+		}
+		writeValue(w, r, http.StatusOK, c)
+	case http.MethodPut:
+		var c mockssh.Conversation
+		if err := readValue(r, &c); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.Name = name
+		if errs := c.Validate(); len(errs) > 0 {
+			http.Error(w, errs.Error(), http.StatusBadRequest)
+			return
+		}
+		status := http.StatusCreated
+		if h.PutConversation(c) {
+			status = http.StatusOK
+		}
+		s.Log.Infof("ssh conversation '%s' updated", name)
+		writeValue(w, r, status, c)
+	case http.MethodDelete:
+		if !h.DeleteConversation(name) {
+			http.Error(w, fmt.Sprintf("conversation '%s' not found", name), http.StatusNotFound)
+			return
+		}
+		s.Log.Infof("ssh conversation '%s' deleted", name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// readSshConversations reads either a single conversation or a list of
+// conversations from the request body, the conversations are validated, as
+// when read from a conversation-file.
+func readSshConversations(r *http.Request) ([]mockssh.Conversation, error) {
+	var raw interface{}
+	if err := readValue(r, &raw); err != nil {
+		return nil, err
+	}
+	var conversations []mockssh.Conversation
+	if err := decodeList(raw, &conversations); err != nil {
+		return nil, err
+	}
+	var errs validation.Errors
+	for i, c := range conversations {
+		errs = append(errs, c.Validate().Within(conversationField(i, c.Name), "")...)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return conversations, nil
+}
@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"github.com/thorsager/mockdev/mockssh"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSshConversations(t *testing.T) {
+	s, reg := newTestServer()
+	h := &mockssh.Handler{Log: s.Log}
+	reg.RegisterSsh("default", h)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		want   string // in the response
+	}{
+		{http.MethodPut, "/api/ssh/default/conversations/ls", `{"request-matcher":"^ls$"}`, http.StatusCreated, "request-matcher"},
+		{http.MethodGet, "/api/ssh/default/conversations/ls", "", http.StatusOK, "^ls$"},
+		{http.MethodDelete, "/api/ssh/default/conversations/ls", "", http.StatusNoContent, ""},
+		{http.MethodGet, "/api/ssh/default/conversations/ls", "", http.StatusNotFound, "not found"},
+	}
+	for _, tt := range tests {
+		w := do(s, tt.method, tt.path, tt.body)
+		expectStatus(t, w, tt.status)
+		if !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s %s = %s, want %s", tt.method, tt.path, w.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestSshConversationsValidated(t *testing.T) {
+	s, reg := newTestServer()
+	h := &mockssh.Handler{Log: s.Log}
+	reg.RegisterSsh("default", h)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		want   string // in the response
+	}{
+		{http.MethodPut, "/api/ssh/default/conversations/bad", `{"request-matcher":"(ls"}`, http.StatusBadRequest, "request-matcher: error parsing regexp"},
+		{http.MethodPost, "/api/ssh/default/conversations", `[{"name":"ok","request-matcher":"^ls$"},{"name":"bad","request-matcher":"[a"}]`, http.StatusBadRequest, "conversations[bad].request-matcher"},
+		{http.MethodPut, "/api/ssh/default/conversations", `{"request-matcher":"^ls$"}`, http.StatusBadRequest, "conversations[0].name: missing name"},
+		{http.MethodPut, "/api/ssh/default/conversations/ls", `{"request-matcher":"^ls$"}`, http.StatusCreated, "request-matcher"},
+	}
+	for _, tt := range tests {
+		w := do(s, tt.method, tt.path, tt.body)
+		expectStatus(t, w, tt.status)
+		if !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s %s = %s, want %s", tt.method, tt.path, w.Body.String(), tt.want)
+		}
+	}
+	if got := len(h.GetConversations()); got != 1 {
+		t.Errorf("conversations = %d, want 1", got)
+	}
+}
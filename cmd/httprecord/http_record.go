@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/thorsager/mockdev/mockhttp"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var Version = "*unset*"
+
+type headerList []string
+
+func (l *headerList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+func (l *headerList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func main() {
+	flag.Usage = func() {
+		bin := filepath.Base(os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "%s Version %s\n", bin, Version)
+		_, _ = fmt.Fprintln(os.Stderr, "Usage:")
+		_, _ = fmt.Fprintf(os.Stderr, "  %s [options] <upstream-url>\n", bin)
+		_, _ = fmt.Fprintln(os.Stderr, "  Options:")
+		_, _ = fmt.Fprintln(os.Stderr, "    -l <addr>        Address to listen on (default: ':8080')")
+		_, _ = fmt.Fprintln(os.Stderr, "    -o <file>        Name of conversation file (default: 'recorded.yaml')")
+		_, _ = fmt.Fprintln(os.Stderr, "    -b <dir>         Directory for body-files (default: '<file>_bodies')")
+		_, _ = fmt.Fprintln(os.Stderr, "    -s <bytes>       Largest body recorded inline (default: 4096)")
+		_, _ = fmt.Fprintln(os.Stderr, "    -m <header>      Request header(s) to record as header-matcher")
+		_, _ = fmt.Fprintln(os.Stderr, "    -r <header>      Header(s) to redact (default: Authorization, Cookie, Set-Cookie)")
+		_, _ = fmt.Fprintln(os.Stderr, "    -d               De-duplicate, only record the first of identical requests")
+		_, _ = fmt.Fprintln(os.Stderr, "    -f               Overwrite conversation file, if exists")
+		_, _ = fmt.Fprintln(os.Stderr, "    -v               Verbose, print out progress")
+		_, _ = fmt.Fprintln(os.Stderr, "  Arguments:")
+		_, _ = fmt.Fprintln(os.Stderr, "    upstream-url     Url of device to record")
+	}
+	var verbose bool
+	flag.BoolVar(&verbose, "v", false, "Verbose, print out progress")
+
+	var overwrite bool
+	flag.BoolVar(&overwrite, "f", false, "Overwrite conversation file, if exists")
+
+	var dedup bool
+	flag.BoolVar(&dedup, "d", false, "De-duplicate, only record the first of identical requests")
+
+	var listen string
+	flag.StringVar(&listen, "l", ":8080", "Address to listen on")
+
+	var output string
+	flag.StringVar(&output, "o", "recorded.yaml", "Name of conversation file")
+
+	var bodyDir string
+	flag.StringVar(&bodyDir, "b", "", "Directory for body-files")
+
+	var maxInline int
+	flag.IntVar(&maxInline, "s", mockhttp.DefaultMaxInlineBody, "Largest body recorded inline")
+
+	var matchHeaders headerList
+	flag.Var(&matchHeaders, "m", "Request header(s) to record as header-matcher")
+
+	var redactHeaders headerList
+	flag.Var(&redactHeaders, "r", "Header(s) to redact")
+
+	flag.Parse()
+
+	if len(flag.Args()) < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	upstream, err := url.Parse(flag.Arg(0))
+	if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid upstream url: '%s'\n", flag.Arg(0))
+		os.Exit(1)
+	}
+
+	if _, err := os.Stat(output); err == nil && !overwrite {
+		_, _ = fmt.Fprintf(os.Stderr, "file '%s' exists, use -f to overwrite\n", output)
+		os.Exit(2)
+	}
+	if bodyDir == "" {
+		bodyDir = strings.TrimSuffix(output, filepath.Ext(output)) + "_bodies"
+	}
+	if len(redactHeaders) == 0 {
+		redactHeaders = mockhttp.DefaultRedactHeaders
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.WarnLevel)
+	if verbose {
+		logger.SetLevel(logrus.DebugLevel)
+	}
+
+	recorder := &mockhttp.Recorder{
+		Log:           logger,
+		File:          output,
+		BodyDir:       bodyDir,
+		MaxInlineBody: maxInline,
+		RedactHeaders: redactHeaders,
+		MatchHeaders:  matchHeaders,
+		Deduplicate:   dedup,
+	}
+	logger.Infof("recording %s on %s to %s", upstream, listen, output)
+	if err := http.ListenAndServe(listen, mockhttp.NewRecordingProxy(upstream, recorder, logger)); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "while serving: %s\n", err)
+		os.Exit(2)
+	}
+}
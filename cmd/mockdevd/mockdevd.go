@@ -4,13 +4,17 @@ import (
 	"flag"
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"github.com/thorsager/mockdev/admin"
 	"github.com/thorsager/mockdev/configuration"
 	"github.com/thorsager/mockdev/mockhttp"
 	"github.com/thorsager/mockdev/mocksnmp"
 	"github.com/thorsager/mockdev/mockssh"
+	"github.com/thorsager/mockdev/registry"
 	"net/http"
 	"os"
-	"sort"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 var Version = "*unset*"
@@ -24,14 +28,30 @@ func main() {
 	var configFile string
 	flag.StringVar(&configFile, "c", "config.yaml", "configuration file")
 
+	var watchInterval time.Duration
+	flag.DurationVar(&watchInterval, "w", 2*time.Second, "interval for checking configuration files for changes (0 disables)")
+
+	var validateOnly bool
+	flag.BoolVar(&validateOnly, "validate", false, "validate configuration and exit")
+
 	flag.Parse()
 
+	if validateOnly {
+		os.Exit(validate(configFile))
+	}
+
 	logger := logrus.New()
 	logger.Infof("reading config from: %s", configFile)
 	config, err := configuration.Read(configFile)
 	if err != nil {
 		logger.Fatalf("while reading config: %v", err)
 	}
+	if errs := configuration.Validate(configFile, config); len(errs) > 0 {
+		for _, e := range errs {
+			logger.Error(e)
+		}
+		logger.Fatalf("invalid configuration, %d error(s) found", len(errs))
+	}
 	logger.Infof("mockdevd %s starting", Version)
 
 	// get log-level from config, but overwrite from env, if found.
@@ -49,28 +69,90 @@ func main() {
 		logger.SetLevel(level)
 	}
 
+	services := registry.New()
+
 	for _, c := range config.Snmp {
 		entry := logger.WithField("type", "snmp")
-		go startSnmpService(c, entry)
+		agent, err := mocksnmp.NewAgent(c, entry.WithField("name", c.Name))
+		if err != nil {
+			entry.Fatalf("while creating server: %v", err)
+		}
+		if !services.RegisterSnmp(c.Name, agent) {
+			entry.Warnf("duplicate snmp service name '%s'", c.Name)
+		}
+		go startSnmpService(c, agent, entry)
 	}
 
 	for _, c := range config.Http {
 		entry := logger.WithField("type", "http")
-		go startHttpService(c, entry)
+		handler, err := newHttpHandler(c, config.LatencyMultiplier, entry)
+		if err != nil {
+			entry.Fatalf("while creating server: %v", err)
+		}
+		if !services.RegisterHttp(c.Name, handler) {
+			entry.Warnf("duplicate http service name '%s'", c.Name)
+		}
+		go startHttpService(c, handler, entry)
 	}
 
 	for _, c := range config.Ssh {
 		entry := logger.WithField("type", "ssh")
-		go startSshService(c, entry)
+		handler, err := mockssh.NewHandler(c, entry)
+		if err != nil {
+			entry.Fatalf("while creating server: %v", err)
+		}
+		if !services.RegisterSsh(c.Name, handler) {
+			entry.Warnf("duplicate ssh service name '%s'", c.Name)
+		}
+		go startSshService(c, handler, entry)
 	}
 
-	// this could be done a lot nicer...
-	select {}
+	if config.Admin != nil {
+		go startAdminService(config.Admin, services, logger.WithField("type", "admin"))
+	}
+
+	watchConfiguration(configFile, config, watchInterval, services, logger.WithField("type", "reload"))
+}
+
+// validate validates the configuration in configFile, printing all errors
+// found, and returns the exit code.
+func validate(configFile string) int {
+	config, err := configuration.Read(configFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %v\n", configFile, err)
+		return 1
+	}
+	errs := configuration.Validate(configFile, config)
+	for _, e := range errs {
+		_, _ = fmt.Fprintln(os.Stderr, e)
+	}
+	if len(errs) > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "%d error(s) found\n", len(errs))
+		return 1
+	}
+	fmt.Printf("%s: ok\n", configFile)
+	return 0
 }
 
-func startSshService(config *mockssh.Configuration, logger *logrus.Entry) {
+// watchConfiguration reloads the configuration, when any of its files change or
+// when SIGHUP is received.
+func watchConfiguration(configFile string, config *configuration.Config, interval time.Duration, services *registry.Registry, logger *logrus.Entry) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	configuration.Watch(configFile, config, interval, hup, nil, services, logger)
+}
+
+func startAdminService(config *admin.Configuration, services *registry.Registry, logger *logrus.Entry) {
+	logger.Infof("Admin API listening on %s", config.BindAddr)
+	err := admin.NewServer(services, logger).ListenAndServe(config.BindAddr)
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+func startSshService(config *mockssh.Configuration, handler *mockssh.Handler, logger *logrus.Entry) {
 	logger.Infof("Server %s listening on %s", config.Name, config.BindAddr)
-	s, err := mockssh.NewServer(config, logger)
+	s, err := mockssh.NewServer(config, handler, logger)
 	if err != nil {
 		logger.Error(err)
 	}
@@ -80,45 +162,58 @@ func startSshService(config *mockssh.Configuration, logger *logrus.Entry) {
 	}
 }
 
-func startHttpService(config *mockhttp.Configuration, logger *logrus.Entry) {
-	conversations := config.Conversations
-	for _, cf := range config.ConversationFiles {
-		// this is where we load the reset of the conversations
-		con, err := mockhttp.DecodeConversationFile(cf)
-		if err != nil {
-			logger.Error(err)
-		} else {
-			conversations = append(conversations, con...)
-		}
+func newHttpHandler(config *mockhttp.Configuration, latencyMultiplier *float64, logger *logrus.Entry) (*mockhttp.ConversationsHandler, error) {
+	conversations, err := config.LoadConversations()
+	if err != nil {
+		return nil, err
 	}
-	sort.Slice(conversations, func(i, j int) bool { return conversations[i].Order < conversations[j].Order })
 	for _, c := range conversations {
 		logger.Infof("loaded conversation[%d]: %s", c.Order, c.Name)
 	}
-	logger.Infof("Server %s listening on %s", config.Name, config.BindAddr)
-	err := http.ListenAndServe(config.BindAddr, &mockhttp.ConversationsHandler{
-		Conversations:      conversations,
+	handler := &mockhttp.ConversationsHandler{
 		Log:                logger,
 		SessionLogReceived: config.Logging.LogReceived,
 		SessionLogLocation: config.Logging.Location,
 		BindAddress:        config.BindAddr,
-	})
-	if err != nil {
-		logger.Error(err)
+		JournalSize:        config.JournalSize,
+		RandomSeed:         config.RandomSeed,
+		LatencyMultiplier:  latencyMultiplier,
+		Unmatched:          config.Unmatched,
+	}
+	handler.SetLoadedConversations(conversations) // compiles the matchers and templates
+	if config.Passthrough != nil {
+		replay := func(c mockhttp.Conversation) { handler.PutConversation(c) }
+		if handler.Passthrough, err = mockhttp.NewPassthroughProxy(*config.Passthrough, logger, replay); err != nil {
+			return nil, err
+		}
 	}
+	return handler, nil
 }
 
-func startSnmpService(config *mocksnmp.Configuration, logger *logrus.Entry) {
-	server, err := mocksnmp.NewServer(config, logger.WithField("name", config.Name))
-	if err != nil {
-		logger.Fatalf("while creating server: %v", err)
+func startHttpService(config *mockhttp.Configuration, handler *mockhttp.ConversationsHandler, logger *logrus.Entry) {
+	server := &http.Server{Addr: config.BindAddr, Handler: handler}
+	if config.TLS == nil {
+		logger.Infof("Server %s listening on %s", config.Name, config.BindAddr)
+		err := server.ListenAndServe()
+		if err != nil {
+			logger.Error(err)
+		}
+		return
+	}
+	if err := config.ConfigureTLS(server); err != nil {
+		logger.Errorf("while configuring tls: %v", err)
+		return
 	}
-	err = server.ListenUDP("udp", config.BindAddr)
+	logger.Infof("Server %s listening on %s (tls)", config.Name, config.BindAddr)
+	err := server.ListenAndServeTLS("", "")
 	if err != nil {
-		logger.Fatalf("while setting up socket: %v", err)
+		logger.Error(err)
 	}
+}
+
+func startSnmpService(config *mocksnmp.Configuration, agent *mocksnmp.Agent, logger *logrus.Entry) {
 	logger.Infof("snmp service '%s' listening on %s (ro=%s,rw=%s)", config.Name, config.BindAddr, config.ReadCommunity, config.WriteCommunity)
-	err = server.ServeForever()
+	err := agent.ListenAndServe(config.BindAddr)
 	if err != nil {
 		logger.Fatalf("while serving: %v", err)
 	}
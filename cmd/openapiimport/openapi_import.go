@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/thorsager/mockdev/mockhttp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var Version = "*unset*"
+
+func main() {
+	flag.Usage = func() {
+		bin := filepath.Base(os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "%s Version %s\n", bin, Version)
+		_, _ = fmt.Fprintln(os.Stderr, "Usage:")
+		_, _ = fmt.Fprintf(os.Stderr, "  %s [options] <openapi-document>\n", bin)
+		_, _ = fmt.Fprintln(os.Stderr, "  Options:")
+		_, _ = fmt.Fprintln(os.Stderr, "    -o <file>        Name of conversation file (default: '<document>_conversations.yaml')")
+		_, _ = fmt.Fprintln(os.Stderr, "    -f               Overwrite conversation file, if exists")
+		_, _ = fmt.Fprintln(os.Stderr, "    -v               Verbose, print out conversations created")
+		_, _ = fmt.Fprintln(os.Stderr, "  Arguments:")
+		_, _ = fmt.Fprintln(os.Stderr, "    openapi-document OpenAPI 3 document, YAML or JSON")
+	}
+	var verbose bool
+	flag.BoolVar(&verbose, "v", false, "Verbose, print out conversations created")
+
+	var overwrite bool
+	flag.BoolVar(&overwrite, "f", false, "Overwrite conversation file, if exists")
+
+	var output string
+	flag.StringVar(&output, "o", "", "Name of conversation file")
+
+	flag.Parse()
+
+	if len(flag.Args()) < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	input := flag.Arg(0)
+	if output == "" {
+		output = strings.TrimSuffix(input, filepath.Ext(input)) + "_conversations.yaml"
+	}
+	if _, err := os.Stat(output); err == nil && !overwrite {
+		_, _ = fmt.Fprintf(os.Stderr, "file '%s' exists, use -f to overwrite\n", output)
+		os.Exit(2)
+	}
+
+	data, err := ioutil.ReadFile(input)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "unable to read '%s': %s\n", input, err)
+		os.Exit(2)
+	}
+	conversations, err := mockhttp.ConversationsFromOpenAPI(data)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "unable to convert '%s': %s\n", input, err)
+		os.Exit(2)
+	}
+	if verbose {
+		for _, c := range conversations {
+			fmt.Printf("%s: %s %s -> %d\n", c.Name, c.Request.MethodMatcher, c.Request.UrlMatcher.Path, c.Response.StatusCode)
+		}
+	}
+	if err := mockhttp.WriteConversationFile(output, conversations); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "unable to write '%s': %s\n", output, err)
+		os.Exit(2)
+	}
+	fmt.Printf("%d conversation(s) written to %s\n", len(conversations), output)
+}
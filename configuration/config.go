@@ -1,6 +1,7 @@
 package configuration
 
 import (
+	"github.com/thorsager/mockdev/mockhttp"
 	"github.com/thorsager/mockdev/util"
 	"gopkg.in/yaml.v2"
 	"os"
@@ -8,7 +9,6 @@ import (
 )
 
 func Read(filename string) (*Config, error) {
-	// TODO: add deep validation of arguments, that the server may fail EARLY
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
@@ -24,9 +24,41 @@ func Read(filename string) (*Config, error) {
 	}
 	for i := 0; i < len(config.Http); i++ {
 		config.Http[i].ConversationFiles = util.MakeFilesAbsolute(path.Dir(filename), config.Http[i].ConversationFiles)
+		makeTLSFilesAbsolute(path.Dir(filename), config.Http[i].TLS)
+		makeRecordFilesAbsolute(path.Dir(filename), config.Http[i].Passthrough)
+		for j := range config.Http[i].VirtualHosts {
+			v := &config.Http[i].VirtualHosts[j]
+			v.ConversationFiles = util.MakeFilesAbsolute(path.Dir(filename), v.ConversationFiles)
+			makeTLSFilesAbsolute(path.Dir(filename), v.TLS)
+		}
 	}
 	for i := 0; i < len(config.Ssh); i++ {
 		config.Ssh[i].ConversationFiles = util.MakeFilesAbsolute(path.Dir(filename), config.Ssh[i].ConversationFiles)
 	}
 	return config, nil
 }
+
+func makeRecordFilesAbsolute(dir string, p *mockhttp.PassthroughConfiguration) {
+	if p == nil || p.Record == nil {
+		return
+	}
+	if p.Record.File != "" {
+		p.Record.File = util.MakeFileAbsolute(dir, p.Record.File)
+	}
+	if p.Record.BodyDir != "" {
+		p.Record.BodyDir = util.MakeFileAbsolute(dir, p.Record.BodyDir)
+	}
+}
+
+func makeTLSFilesAbsolute(dir string, t *mockhttp.TLSConfiguration) {
+	if t == nil {
+		return
+	}
+	if t.CertFile != "" {
+		t.CertFile = util.MakeFileAbsolute(dir, t.CertFile)
+	}
+	if t.KeyFile != "" {
+		t.KeyFile = util.MakeFileAbsolute(dir, t.KeyFile)
+	}
+	t.ClientCAFiles = util.MakeFilesAbsolute(dir, t.ClientCAFiles)
+}
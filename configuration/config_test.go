@@ -0,0 +1,40 @@
+package configuration
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRead_RelativeFiles(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.yaml")
+	config := `
+http:
+  - name: web
+    bind-addr: "127.0.0.1:0"
+    conversation-files: [web.yaml]
+    passthrough:
+      upstream: http://device.example.com
+      record:
+        file: recorded/device.yaml
+        body-dir: /var/bodies
+`
+	if err := ioutil.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Read(filename)
+	if err != nil {
+		t.Fatalf("Read() %v", err)
+	}
+	if got, want := c.Http[0].ConversationFiles[0], filepath.Join(dir, "web.yaml"); got != want {
+		t.Errorf("conversation-file %s, expected %s", got, want)
+	}
+	record := c.Http[0].Passthrough.Record
+	if want := filepath.Join(dir, "recorded", "device.yaml"); record.File != want {
+		t.Errorf("record file %s, expected %s", record.File, want)
+	}
+	if record.BodyDir != "/var/bodies" {
+		t.Errorf("record body-dir %s, expected it unchanged", record.BodyDir)
+	}
+}
@@ -1,6 +1,7 @@
 package configuration
 
 import (
+	"github.com/thorsager/mockdev/admin"
 	"github.com/thorsager/mockdev/mockhttp"
 	"github.com/thorsager/mockdev/mocksnmp"
 	"github.com/thorsager/mockdev/mockssh"
@@ -11,4 +12,7 @@ type Config struct {
 	Snmp     []*mocksnmp.Configuration `yaml:"snmp"`
 	Http     []*mockhttp.Configuration `yaml:"http"`
 	Ssh      []*mockssh.Configuration  `yaml:"ssh"`
+	Admin    *admin.Configuration      `yaml:"admin"`
+	// LatencyMultiplier scales all HTTP response delays, 0 disables them.
+	LatencyMultiplier *float64 `yaml:"latency-multiplier,omitempty"`
 }
@@ -0,0 +1,148 @@
+package configuration
+
+import (
+	"fmt"
+	"github.com/thorsager/mockdev/filewatch"
+	"github.com/thorsager/mockdev/logging"
+	"github.com/thorsager/mockdev/mockhttp"
+	"github.com/thorsager/mockdev/mocksnmp"
+	"github.com/thorsager/mockdev/mockssh"
+	"github.com/thorsager/mockdev/registry"
+	"os"
+	"strings"
+	"time"
+)
+
+// Files returns all conversation- and snapshot-files referenced by the
+// configuration.
+func (c *Config) Files() []string {
+	var files []string
+	for _, s := range c.Snmp {
+		files = append(files, s.SnapshotFiles...)
+	}
+	for _, h := range c.Http {
+		files = append(files, h.ConversationFiles...)
+		for _, v := range h.VirtualHosts {
+			files = append(files, v.ConversationFiles...)
+		}
+	}
+	for _, s := range c.Ssh {
+		files = append(files, s.ConversationFiles...)
+	}
+	return files
+}
+
+// Watch reloads the configuration in filename, read as config, when any of its
+// files change, polling every interval if positive, or when a signal is
+// received on hup, until stop is closed. If the reloaded configuration fails
+// to load, the current configuration is kept.
+func Watch(filename string, config *Config, interval time.Duration, hup <-chan os.Signal, stop <-chan struct{}, reg *registry.Registry, logger logging.Logger) {
+	changes := make(chan []string)
+	watcher := filewatch.New(interval)
+	watcher.Watch(append(config.Files(), filename)...)
+	if interval > 0 {
+		go watcher.Run(stop, func(changed []string) {
+			select {
+			case changes <- changed:
+			case <-stop:
+			}
+		})
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+			logger.Info("SIGHUP received, reloading configuration")
+		case changed := <-changes:
+			logger.Infof("changes detected in %s, reloading configuration", strings.Join(changed, ", "))
+		}
+		reloaded, err := Reload(filename, reg, logger)
+		if err != nil {
+			logger.Errorf("reload failed, keeping current configuration: %v", err)
+			continue
+		}
+		watcher.Watch(append(reloaded.Files(), filename)...)
+		logger.Info("configuration reloaded")
+	}
+}
+
+// Reload reads and validates the configuration file filename, and applies it
+// to the services in reg. If any of it fails the services are left unchanged.
+func Reload(filename string, reg *registry.Registry, logger logging.Logger) (*Config, error) {
+	config, err := Read(filename)
+	if err != nil {
+		return nil, err
+	}
+	if errs := Validate(filename, config); len(errs) > 0 {
+		return nil, errs
+	}
+	if err := Apply(config, reg, logger); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Apply loads the conversations and OIDs of config, and swaps them into the
+// services of the same name found in reg, keeping conversations added at
+// runtime. If loading fails for any service nothing is changed. Services not
+// found in reg are ignored, as adding services requires a restart.
+func Apply(config *Config, reg *registry.Registry, logger logging.Logger) error {
+	httpConversations := make(map[*mockhttp.ConversationsHandler][]mockhttp.Conversation)
+	for _, c := range config.Http {
+		h, found := reg.Http(c.Name)
+		if !found {
+			logger.Warnf("http service '%s' is not running, restart needed", c.Name)
+			continue
+		}
+		conversations, err := c.LoadConversations()
+		if err != nil {
+			return fmt.Errorf("http service '%s': %w", c.Name, err)
+		}
+		httpConversations[h] = conversations
+	}
+
+	sshConversations := make(map[*mockssh.Handler][]mockssh.Conversation)
+	for _, c := range config.Ssh {
+		h, found := reg.Ssh(c.Name)
+		if !found {
+			logger.Warnf("ssh service '%s' is not running, restart needed", c.Name)
+			continue
+		}
+		conversations, err := c.LoadConversations()
+		if err != nil {
+			return fmt.Errorf("ssh service '%s': %w", c.Name, err)
+		}
+		sshConversations[h] = conversations
+	}
+
+	snmpOIDs := make(map[*mocksnmp.Agent]*mocksnmp.PreparedOIDs)
+	for _, c := range config.Snmp {
+		a, found := reg.Snmp(c.Name)
+		if !found {
+			logger.Warnf("snmp service '%s' is not running, restart needed", c.Name)
+			continue
+		}
+		oids, err := c.LoadOIDs()
+		if err != nil {
+			return fmt.Errorf("snmp service '%s': %w", c.Name, err)
+		}
+		prepared, err := a.PrepareOIDs(oids...)
+		if err != nil {
+			return fmt.Errorf("snmp service '%s': %w", c.Name, err)
+		}
+		snmpOIDs[a] = prepared
+	}
+
+	for h, conversations := range httpConversations {
+		h.SetLoadedConversations(conversations)
+	}
+	for h, conversations := range sshConversations {
+		h.SetLoadedConversations(conversations)
+	}
+	for a, prepared := range snmpOIDs {
+		a.ServeOIDs(prepared)
+	}
+	return nil
+}
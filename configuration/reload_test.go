@@ -0,0 +1,219 @@
+package configuration
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/thorsager/mockdev/mockhttp"
+	"github.com/thorsager/mockdev/mocksnmp"
+	"github.com/thorsager/mockdev/registry"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const reloadTestConfig = `
+http:
+  - name: web
+    bind-addr: "127.0.0.1:0"
+    conversation-files: [web.yaml]
+snmp:
+  - name: agent
+    bind-addr: "127.0.0.1:0"
+    community-ro: public
+    oids:
+      - ".1.3.6.1.2.1.1.5.0/4/string/%s"
+`
+
+const reloadTestConversation = `
+- name: hello
+  request:
+    url-matcher:
+      path: "^/hello$"
+    method-matcher: "^GET$"
+  response:
+    status-code: 200
+    body: %s
+`
+
+func writeFile(t *testing.T, filename string, format string, arg string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filename, []byte(fmt.Sprintf(format, arg)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startServices writes the configuration to dir, and registers the services it
+// configures, the way mockdevd does.
+func startServices(t *testing.T, dir string) (string, *registry.Registry, *mockhttp.ConversationsHandler, *mocksnmp.Agent) {
+	t.Helper()
+	filename := filepath.Join(dir, "config.yaml")
+	writeFile(t, filename, reloadTestConfig, "before")
+	writeFile(t, filepath.Join(dir, "web.yaml"), reloadTestConversation, "before")
+	config, err := Read(filename)
+	if err != nil {
+		t.Fatalf("Read() %v", err)
+	}
+	logger := discardLogger()
+	conversations, err := config.Http[0].LoadConversations()
+	if err != nil {
+		t.Fatalf("LoadConversations() %v", err)
+	}
+	h := &mockhttp.ConversationsHandler{Log: logger}
+	h.SetLoadedConversations(conversations)
+	a, err := mocksnmp.NewAgent(config.Snmp[0], logger)
+	if err != nil {
+		t.Fatalf("NewAgent() %v", err)
+	}
+	reg := registry.New()
+	reg.RegisterHttp("web", h)
+	reg.RegisterSnmp("agent", a)
+	return filename, reg, h, a
+}
+
+func discardLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	return logger
+}
+
+func body(h *mockhttp.ConversationsHandler, name string) string {
+	c, _ := h.GetConversation(name)
+	return c.Response.Body
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	filename, reg, h, a := startServices(t, dir)
+	writeFile(t, filename, reloadTestConfig, "after")
+	writeFile(t, filepath.Join(dir, "web.yaml"), reloadTestConversation, "after")
+
+	config, err := Reload(filename, reg, discardLogger())
+	if err != nil {
+		t.Fatalf("Reload() %v", err)
+	}
+	if want := []string{filepath.Join(dir, "web.yaml")}; !reflect.DeepEqual(config.Files(), want) {
+		t.Errorf("Files() %v, expected %v", config.Files(), want)
+	}
+	if got := body(h, "hello"); got != "after" {
+		t.Errorf("conversation body %s, expected after", got)
+	}
+	if got, _ := a.GetOID(".1.3.6.1.2.1.1.5.0"); got != ".1.3.6.1.2.1.1.5.0/4/string/after" {
+		t.Errorf("GetOID() %s, expected the reloaded value", got)
+	}
+}
+
+func TestReload_Rejected(t *testing.T) {
+	dir := t.TempDir()
+	filename, reg, h, a := startServices(t, dir)
+	writeFile(t, filename, reloadTestConfig, "after")
+	writeFile(t, filepath.Join(dir, "web.yaml"), reloadTestConversation+"    delay: fast\n", "after")
+
+	if _, err := Reload(filename, reg, discardLogger()); err == nil {
+		t.Fatalf("Reload() no error, expected the conversation-file to be rejected")
+	}
+	if got := body(h, "hello"); got != "before" {
+		t.Errorf("conversation body %s, expected before", got)
+	}
+	if got, _ := a.GetOID(".1.3.6.1.2.1.1.5.0"); got != ".1.3.6.1.2.1.1.5.0/4/string/before" {
+		t.Errorf("GetOID() %s, expected the value before the reload", got)
+	}
+}
+
+// TestApply_Unservable checks that no service is changed, if the OIDs of a
+// later service parse, but the agent cannot serve them.
+func TestApply_Unservable(t *testing.T) {
+	dir := t.TempDir()
+	filename, reg, h, a := startServices(t, dir)
+	writeFile(t, filename, reloadTestConfig, "after")
+	writeFile(t, filepath.Join(dir, "web.yaml"), reloadTestConversation, "after")
+	config, err := Read(filename)
+	if err != nil {
+		t.Fatalf("Read() %v", err)
+	}
+	a.WriteCommunity = a.ReadCommunity // rejected by the snmp server
+
+	if err := Apply(config, reg, discardLogger()); err == nil {
+		t.Fatalf("Apply() no error, expected the oids to be rejected")
+	}
+	if got, _ := a.GetOID(".1.3.6.1.2.1.1.5.0"); got != ".1.3.6.1.2.1.1.5.0/4/string/before" {
+		t.Errorf("GetOID() %s, expected the value before the reload", got)
+	}
+	if got := body(h, "hello"); got != "before" {
+		t.Errorf("conversation body %s, expected before", got)
+	}
+}
+
+func TestReload_KeepsRuntimeConversations(t *testing.T) {
+	dir := t.TempDir()
+	filename, reg, h, _ := startServices(t, dir)
+	h.PutConversation(mockhttp.Conversation{Name: "added", Response: mockhttp.Response{StatusCode: 200, Body: "added"}})
+	writeFile(t, filepath.Join(dir, "web.yaml"), reloadTestConversation, "after")
+	if _, err := Reload(filename, reg, discardLogger()); err != nil {
+		t.Fatalf("Reload() %v", err)
+	}
+	if got := body(h, "added"); got != "added" {
+		t.Errorf("conversation added at runtime served %q after reload, expected added", got)
+	}
+
+	h.PutConversation(mockhttp.Conversation{Name: "hello", Response: mockhttp.Response{StatusCode: 200, Body: "replaced"}})
+	if _, err := Reload(filename, reg, discardLogger()); err != nil {
+		t.Fatalf("Reload() %v", err)
+	}
+	if got := body(h, "hello"); got != "replaced" {
+		t.Errorf("conversation body %s, expected the conversation replaced at runtime", got)
+	}
+	if got := len(h.GetConversations()); got != 2 {
+		t.Errorf("%d conversations served, expected 2", got)
+	}
+
+	h.DeleteConversation("hello")
+	if _, err := Reload(filename, reg, discardLogger()); err != nil {
+		t.Fatalf("Reload() %v", err)
+	}
+	if got := body(h, "hello"); got != "after" {
+		t.Errorf("conversation body %s, expected the loaded conversation after delete", got)
+	}
+}
+
+// waitForBody waits for the conversation name of h to serve want.
+func waitForBody(t *testing.T, h *mockhttp.ConversationsHandler, name string, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for body(h, name) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("conversation body %s, expected %s", body(h, name), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatch_SIGHUP(t *testing.T) {
+	dir := t.TempDir()
+	filename, reg, h, _ := startServices(t, dir)
+	config, _ := Read(filename)
+	hup := make(chan os.Signal)
+	stop := make(chan struct{})
+	defer close(stop)
+	go Watch(filename, config, 0, hup, stop, reg, discardLogger())
+
+	writeFile(t, filepath.Join(dir, "web.yaml"), reloadTestConversation, "after")
+	hup <- syscall.SIGHUP
+	waitForBody(t, h, "hello", "after")
+}
+
+func TestWatch_Changes(t *testing.T) {
+	dir := t.TempDir()
+	filename, reg, h, _ := startServices(t, dir)
+	config, _ := Read(filename)
+	stop := make(chan struct{})
+	defer close(stop)
+	go Watch(filename, config, 10*time.Millisecond, nil, stop, reg, discardLogger())
+	time.Sleep(50 * time.Millisecond) // the files are watched, from their state when Watch starts
+
+	writeFile(t, filepath.Join(dir, "web.yaml"), reloadTestConversation, "after, changed")
+	waitForBody(t, h, "hello", "after, changed")
+}
@@ -0,0 +1,47 @@
+package configuration
+
+import (
+	"fmt"
+	"github.com/thorsager/mockdev/validation"
+	"io/ioutil"
+	"net"
+)
+
+// Validate does a deep validation of config, and all the files it references.
+// It will report all errors found, errors that are not located in a referenced
+// file are located in filename, from which config was read.
+func Validate(filename string, config *Config) validation.Errors {
+	var errs validation.Errors
+	for i, c := range config.Snmp {
+		errs = append(errs, c.Validate().Within(serviceField("snmp", i, c.Name), c.Name).Anchored("snmp:")...)
+	}
+	for i, c := range config.Http {
+		errs = append(errs, c.Validate().Within(serviceField("http", i, c.Name), c.Name).Anchored("http:")...)
+	}
+	for i, c := range config.Ssh {
+		errs = append(errs, c.Validate().Within(serviceField("ssh", i, c.Name), c.Name).Anchored("ssh:")...)
+	}
+	if config.Admin != nil {
+		var adminErrs validation.Errors
+		if _, _, err := net.SplitHostPort(config.Admin.BindAddr); err != nil {
+			adminErrs.Add("bind-addr", config.Admin.BindAddr, err)
+		}
+		errs = append(errs, adminErrs.Within("admin", "admin:")...)
+	}
+	if config.LatencyMultiplier != nil && *config.LatencyMultiplier < 0 {
+		errs.Add("latency-multiplier", "latency-multiplier:", fmt.Errorf("must not be negative"))
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		errs.AddAt(filename, 0, "", err)
+		return errs
+	}
+	return errs.Locate(filename, data)
+}
+
+func serviceField(kind string, i int, name string) string {
+	if name == "" {
+		return fmt.Sprintf("%s[%d]", kind, i)
+	}
+	return fmt.Sprintf("%s[%s]", kind, name)
+}
@@ -0,0 +1,79 @@
+package filewatch
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Watcher polls a set of files for changes, a file is considered changed if its
+// modification time or size changes, or if it is created or removed. Polling is
+// used as it works the same on all platforms and across docker bind-mounts.
+type Watcher struct {
+	sync.Mutex
+	Interval time.Duration
+	files    map[string]fileState
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func New(interval time.Duration) *Watcher {
+	return &Watcher{Interval: interval, files: make(map[string]fileState)}
+}
+
+// Watch replaces the set of files being watched, the current state of the
+// files is used as the baseline for detecting changes.
+func (w *Watcher) Watch(files ...string) {
+	w.Lock()
+	defer w.Unlock()
+	w.files = make(map[string]fileState, len(files))
+	for _, f := range files {
+		w.files[f] = stat(f)
+	}
+}
+
+// Changed returns the files that have changed since the last call to Changed
+// or Watch.
+func (w *Watcher) Changed() []string {
+	w.Lock()
+	defer w.Unlock()
+	var changed []string
+	for f, old := range w.files {
+		if current := stat(f); current != old {
+			w.files[f] = current
+			changed = append(changed, f)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Run polls the watched files every Interval, calling onChange with the changed
+// files, until stop is closed.
+func (w *Watcher) Run(stop <-chan struct{}, onChange func(changed []string)) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if changed := w.Changed(); len(changed) > 0 {
+				onChange(changed)
+			}
+		}
+	}
+}
+
+func stat(file string) fileState {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: fi.ModTime(), size: fi.Size(), exists: true}
+}
@@ -0,0 +1,81 @@
+package filewatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatcher_Changed(t *testing.T) {
+	dir := t.TempDir()
+	a, b, created := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "created")
+	for _, f := range []string{a, b} {
+		if err := ioutil.WriteFile(f, []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := New(0)
+	w.Watch(a, b, created)
+	if changed := w.Changed(); len(changed) != 0 {
+		t.Fatalf("Changed() %v, expected none", changed)
+	}
+
+	if err := ioutil.WriteFile(a, []byte("changed content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(created, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if changed, want := w.Changed(), []string{a, b, created}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("Changed() %v, expected %v", changed, want)
+	}
+	if changed := w.Changed(); len(changed) != 0 {
+		t.Errorf("Changed() %v, expected none since the last call", changed)
+	}
+
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(created, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if changed, want := w.Changed(), []string{created}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("Changed() %v, expected %v after touch", changed, want)
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "f")
+	w := New(10 * time.Millisecond)
+	w.Watch(f)
+	stop := make(chan struct{})
+	changes := make(chan []string, 1)
+	done := make(chan struct{})
+	go func() {
+		w.Run(stop, func(changed []string) { changes <- changed })
+		close(done)
+	}()
+
+	if err := ioutil.WriteFile(f, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case changed := <-changes:
+		if want := []string{f}; !reflect.DeepEqual(changed, want) {
+			t.Errorf("onChange(%v), expected %v", changed, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("onChange not called")
+	}
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Run() did not return when stopped")
+	}
+}
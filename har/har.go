@@ -0,0 +1,216 @@
+// Package har reads and writes HTTP Archives (HAR 1.2), as exported by browser
+// developer tools and many proxies.
+package har
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version is the HAR version written.
+const Version = "1.2"
+
+// HAR is the root object of an HTTP Archive.
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+	Comment string  `json:"comment,omitempty"`
+}
+
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry is a single exchange, Time is the total time of the exchange in ms.
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Time            float64   `json:"time"`
+	Request         Request   `json:"request"`
+	Response        Response  `json:"response"`
+	Cache           struct{}  `json:"cache"`
+	Timings         Timings   `json:"timings"`
+	ServerIPAddress string    `json:"serverIPAddress,omitempty"`
+	Comment         string    `json:"comment,omitempty"`
+}
+
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+	Comment     string      `json:"comment,omitempty"`
+}
+
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+	Comment     string      `json:"comment,omitempty"`
+}
+
+type NameValue struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type Cookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type PostData struct {
+	MimeType string      `json:"mimeType"`
+	Text     string      `json:"text"`
+	Params   []NameValue `json:"params,omitempty"`
+}
+
+// Content is a response body, Text is base64 encoded if Encoding is "base64".
+type Content struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// Timings of an exchange in ms, -1 if not applicable.
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// Decode parses data as an HTTP Archive.
+func Decode(data []byte) (*HAR, error) {
+	var h HAR
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, err
+	}
+	if h.Log.Version == "" && h.Log.Entries == nil {
+		return nil, fmt.Errorf("not an HTTP Archive, missing log")
+	}
+	return &h, nil
+}
+
+// IsHAR returns true if data looks like an HTTP Archive, a JSON object with a log
+// holding entries.
+func IsHAR(data []byte) bool {
+	var probe struct {
+		Log *struct {
+			Entries json.RawMessage `json:"entries"`
+		} `json:"log"`
+	}
+	return json.Unmarshal(data, &probe) == nil && probe.Log != nil && probe.Log.Entries != nil
+}
+
+// New returns an empty HAR created by creator.
+func New(creator string, version string) *HAR {
+	return &HAR{Log: Log{Version: Version, Creator: Creator{Name: creator, Version: version}, Entries: []Entry{}}}
+}
+
+// Header returns the headers as an http.Header, leaving out HTTP/2 pseudo-headers.
+func Header(headers []NameValue) http.Header {
+	header := make(http.Header)
+	for _, h := range headers {
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		header.Add(h.Name, h.Value)
+	}
+	return header
+}
+
+// Headers returns header as a list of name/values, sorted by name.
+func Headers(header http.Header) []NameValue {
+	headers := []NameValue{}
+	for _, name := range sortedKeys(header) {
+		for _, v := range header[name] {
+			headers = append(headers, NameValue{Name: name, Value: v})
+		}
+	}
+	return headers
+}
+
+// QueryString returns the parameters of query as a list of name/values.
+func QueryString(query url.Values) []NameValue {
+	params := []NameValue{}
+	for _, name := range sortedKeys(query) {
+		for _, v := range query[name] {
+			params = append(params, NameValue{Name: name, Value: v})
+		}
+	}
+	return params
+}
+
+// Body returns the decoded body of the content.
+func (c Content) Body() ([]byte, error) {
+	if c.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(c.Text)
+	}
+	return []byte(c.Text), nil
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// HTTPRequest returns the request of the entry as an *http.Request, and its body.
+func (e Entry) HTTPRequest() (*http.Request, []byte, error) {
+	var body []byte
+	if e.Request.PostData != nil {
+		body = []byte(e.Request.PostData.Text)
+	}
+	req, err := http.NewRequest(e.Request.Method, e.Request.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header = Header(e.Request.Headers)
+	return req, body, nil
+}
+
+// HTTPResponse returns the response of the entry as an *http.Response, and its
+// decoded body.
+func (e Entry) HTTPResponse() (*http.Response, []byte, error) {
+	body, err := e.Response.Content.Body()
+	if err != nil {
+		return nil, nil, err
+	}
+	resp := &http.Response{
+		StatusCode: e.Response.Status,
+		Status:     fmt.Sprintf("%d %s", e.Response.Status, e.Response.StatusText),
+		Header:     Header(e.Response.Headers),
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}
+	return resp, body, nil
+}
@@ -0,0 +1,89 @@
+package har
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+const archive = `{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "test", "version": "1"},
+    "entries": [{
+      "startedDateTime": "2023-05-01T10:00:00.000+02:00",
+      "time": 12.5,
+      "request": {
+        "method": "POST",
+        "url": "https://device.example.com/api/items?x=1",
+        "httpVersion": "HTTP/2",
+        "headers": [{"name": ":authority", "value": "device.example.com"}, {"name": "content-type", "value": "application/json"}],
+        "queryString": [{"name": "x", "value": "1"}],
+        "postData": {"mimeType": "application/json", "text": "{\"a\":1}"},
+        "headersSize": -1,
+        "bodySize": 7
+      },
+      "response": {
+        "status": 201,
+        "statusText": "Created",
+        "httpVersion": "HTTP/2",
+        "headers": [{"name": "content-type", "value": "application/octet-stream"}],
+        "content": {"size": 3, "mimeType": "application/octet-stream", "text": "AQID", "encoding": "base64"},
+        "redirectURL": "",
+        "headersSize": -1,
+        "bodySize": 3
+      },
+      "cache": {},
+      "timings": {"send": 0, "wait": 12.5, "receive": 0}
+    }]
+  }
+}`
+
+func TestEntry_HTTPRequest(t *testing.T) {
+	h, err := Decode([]byte(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, body, err := h.Log.Entries[0].HTTPRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "POST" || req.URL.Path != "/api/items" || req.URL.RawQuery != "x=1" || string(body) != `{"a":1}` {
+		t.Errorf("HTTPRequest() = %s %s %s", req.Method, req.URL, body)
+	}
+	if req.Header.Get("Content-Type") != "application/json" || len(req.Header) != 1 {
+		t.Errorf("HTTPRequest() headers = %v, want only Content-Type", req.Header)
+	}
+}
+
+func TestEntry_HTTPResponse(t *testing.T) {
+	h, err := Decode([]byte(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, body, err := h.Log.Entries[0].HTTPResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != 201 || string(body) != "\x01\x02\x03" || string(read) != string(body) {
+		t.Errorf("HTTPResponse() = %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestIsHAR(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{archive, true},
+		{`{"log": {"entries": []}}`, true},
+		{`{"log": {}}`, false},
+		{"- name: conversation", false},
+		{"openapi: 3.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := IsHAR([]byte(tt.data)); got != tt.want {
+			t.Errorf("IsHAR(%.20q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
@@ -55,6 +55,16 @@ func (q *HeaderExpr) ContainedInHeader(h http.Header) bool {
 	return q.ContainedInMap(m)
 }
 
+// SubmatchHeader will return the named groups matched in the first value of each
+// header of the http.Header, mapped by header name and group name.
+func (q *HeaderExpr) SubmatchHeader(h http.Header) map[string]map[string]string {
+	m := make(map[string]string)
+	for k, v := range h {
+		m[k] = v[0]
+	}
+	return q.Submatches(m)
+}
+
 // Compile will create a HeaderExpr from a string in URL query format, but with
 // the twist that all parameter will be treated as a RegularExpression.
 // ex.
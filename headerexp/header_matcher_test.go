@@ -92,3 +92,26 @@ func TestHeaderExpr_MatchStringExact(t *testing.T) {
 		})
 	}
 }
+
+func TestHeaderExpr_SubmatchHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    []string
+		headers []string
+		want    map[string]map[string]string
+	}{
+		{"named", a("authorization: ^Bearer (?P<token>.+)$"), a("Authorization: Bearer abc"), map[string]map[string]string{"Authorization": {"token": "abc"}}},
+		{"unnamed", a("authorization: ^Bearer (.+)$"), a("Authorization: Bearer abc"), map[string]map[string]string{}},
+		{"no_match", a("authorization: ^Bearer (?P<token>.+)$"), a("Authorization: Basic abc"), map[string]map[string]string{}},
+		{"missing", a("authorization: ^Bearer (?P<token>.+)$"), a("F: b"), map[string]map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := MustCompile(tt.expr...)
+			h, _ := stringsToHeaders(tt.headers...)
+			if got := q.SubmatchHeader(h); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SubmatchHeader() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
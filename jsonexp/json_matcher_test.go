@@ -0,0 +1,95 @@
+package jsonexp
+
+import (
+	"reflect"
+	"testing"
+)
+
+const doc = `{
+  "device": {"name": "sw-01", "ports": [{"id": 1, "up": true}, {"id": 2, "up": false}]},
+  "tags": ["core", "lab"],
+  "odd.key": {"x": null},
+  "count": 10.50
+}`
+
+func TestPath_Select(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{"root-child", "$.device.name", []string{"sw-01"}},
+		{"bracket", "$['device']['name']", []string{"sw-01"}},
+		{"bracket-dot", `$["odd.key"].x`, []string{"null"}},
+		{"index", "$.tags[1]", []string{"lab"}},
+		{"negative-index", "$.tags[-1]", []string{"lab"}},
+		{"out-of-range", "$.tags[2]", nil},
+		{"wildcard", "$.tags[*]", []string{"core", "lab"}},
+		{"wildcard-dot", "$.device.ports.*.id", []string{"1", "2"}},
+		{"recursive", "$..id", []string{"1", "2"}},
+		{"recursive-bracket", "$..ports[0].up", []string{"true"}},
+		{"number", "$.count", []string{"10.50"}},
+		{"object", "$.device.ports[0]", []string{`{"id":1,"up":true}`}},
+		{"missing", "$.nope", nil},
+	}
+	d, err := Decode([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range MustCompilePath(tt.path).Select(d) {
+				got = append(got, ValueString(v))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompilePath_Invalid(t *testing.T) {
+	for _, p := range []string{"device.name", "$.", "$..", "$[", "$['a'", "$[a]", "$x"} {
+		if _, err := CompilePath(p); err == nil {
+			t.Errorf("CompilePath(%q) expected error", p)
+		}
+	}
+}
+
+func TestJsonExpr_Extract(t *testing.T) {
+	tests := []struct {
+		name      string
+		matchers  map[string]string
+		want      map[string]string
+		wantMatch bool
+	}{
+		{"regex", map[string]string{"$.device.name": `^sw-\d+$`}, map[string]string{"$.device.name": "sw-01"}, true},
+		{"equals", map[string]string{"$.tags[0]": "==core"}, map[string]string{"$.tags[0]": "core"}, true},
+		{"equals_fail", map[string]string{"$.tags[0]": "==cor"}, map[string]string{}, false},
+		{"any-of-many", map[string]string{"$..up": "^false$"}, map[string]string{"$..up": "false"}, true},
+		{"multi_fail", map[string]string{"$.device.name": "sw", "$.missing": ".*"}, map[string]string{"$.device.name": "sw-01"}, false},
+	}
+	d, err := Decode([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, match := MustCompile(tt.matchers).Extract(d)
+			if !reflect.DeepEqual(got, tt.want) || match != tt.wantMatch {
+				t.Errorf("Extract() = %v, %v, want %v, %v", got, match, tt.want, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestJsonExpr_Match(t *testing.T) {
+	e := MustCompile(map[string]string{"$.a": "^1$"})
+	if !e.Match([]byte(`{"b": 2, "a": 1}`)) {
+		t.Errorf("Match() = false, want true")
+	}
+	if e.Match([]byte(`not json`)) {
+		t.Errorf("Match() = true on invalid json, want false")
+	}
+}
@@ -0,0 +1,231 @@
+package jsonexp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type stepKind int
+
+const (
+	childStep stepKind = iota
+	indexStep
+	wildcardStep
+)
+
+type step struct {
+	kind      stepKind
+	key       string
+	index     int
+	recursive bool // apply to the node and all its descendants
+}
+
+// Path is a compiled JSONPath expression, supporting the subset: root '$',
+// children '.name' and "['name']", array indices '[0]' (negative counting from
+// the end), wildcards '.*' and '[*]' and recursive descent '..name'.
+type Path struct {
+	expr  string
+	steps []step
+}
+
+func (p *Path) String() string {
+	return p.expr
+}
+
+// CompilePath parses a JSONPath expression into a Path.
+func CompilePath(expr string) (*Path, error) {
+	s := strings.TrimSpace(expr)
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("path '%s' must start with '$'", expr)
+	}
+	p := &Path{expr: expr}
+	i := 1
+	for i < len(s) {
+		recursive := false
+		switch {
+		case strings.HasPrefix(s[i:], ".."):
+			recursive = true
+			i += 2
+			if i < len(s) && s[i] == '[' {
+				break
+			}
+			fallthrough
+		case s[i] == '.':
+			if !recursive {
+				i++
+			}
+			end := i
+			for end < len(s) && s[end] != '.' && s[end] != '[' {
+				end++
+			}
+			name := s[i:end]
+			if name == "" {
+				return nil, fmt.Errorf("path '%s': missing name at %d", expr, i)
+			}
+			if name == "*" {
+				p.steps = append(p.steps, step{kind: wildcardStep, recursive: recursive})
+			} else {
+				p.steps = append(p.steps, step{kind: childStep, key: name, recursive: recursive})
+			}
+			i = end
+			continue
+		}
+		if i >= len(s) || s[i] != '[' {
+			return nil, fmt.Errorf("path '%s': unexpected '%s' at %d", expr, s[i:], i)
+		}
+		st, n, err := parseBracket(s[i:])
+		if err != nil {
+			return nil, fmt.Errorf("path '%s': %v", expr, err)
+		}
+		st.recursive = recursive
+		p.steps = append(p.steps, st)
+		i += n
+	}
+	return p, nil
+}
+
+// MustCompilePath is like CompilePath, but panics if the expression cannot be
+// parsed.
+func MustCompilePath(expr string) *Path {
+	p, err := CompilePath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// parseBracket parses a bracket step at the start of s, and returns the step
+// and the number of bytes consumed.
+func parseBracket(s string) (step, int, error) {
+	if len(s) < 2 {
+		return step{}, 0, fmt.Errorf("unterminated %s", s)
+	}
+	end := strings.IndexByte(s, ']')
+	if q := s[1:2]; q == "'" || q == `"` {
+		closing := strings.Index(s[2:], q+"]")
+		if closing < 0 {
+			return step{}, 0, fmt.Errorf("unterminated %s", s)
+		}
+		return step{kind: childStep, key: s[2 : 2+closing]}, closing + 4, nil
+	}
+	if end < 0 {
+		return step{}, 0, fmt.Errorf("unterminated %s", s)
+	}
+	inner := strings.TrimSpace(s[1:end])
+	if inner == "*" {
+		return step{kind: wildcardStep}, end + 1, nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return step{}, 0, fmt.Errorf("invalid index '%s'", inner)
+	}
+	return step{kind: indexStep, index: index}, end + 1, nil
+}
+
+// Select returns all values in doc, selected by the path. doc is expected to
+// be decoded by encoding/json into interface{}.
+func (p *Path) Select(doc interface{}) []interface{} {
+	nodes := []interface{}{doc}
+	for _, st := range p.steps {
+		var next []interface{}
+		for _, n := range nodes {
+			if st.recursive {
+				for _, d := range descendants(n) {
+					next = append(next, st.apply(d)...)
+				}
+			} else {
+				next = append(next, st.apply(n)...)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func (st step) apply(node interface{}) []interface{} {
+	switch st.kind {
+	case childStep:
+		if m, ok := node.(map[string]interface{}); ok {
+			if v, found := m[st.key]; found {
+				return []interface{}{v}
+			}
+		}
+	case indexStep:
+		if a, ok := node.([]interface{}); ok {
+			i := st.index
+			if i < 0 {
+				i += len(a)
+			}
+			if i >= 0 && i < len(a) {
+				return []interface{}{a[i]}
+			}
+		}
+	case wildcardStep:
+		return children(node)
+	}
+	return nil
+}
+
+// children returns the values of an object, ordered by key, or the elements
+// of an array.
+func children(node interface{}) []interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		values := make([]interface{}, 0, len(n))
+		for _, k := range keys {
+			values = append(values, n[k])
+		}
+		return values
+	case []interface{}:
+		return n
+	}
+	return nil
+}
+
+// descendants returns node and all values below it, depth first.
+func descendants(node interface{}) []interface{} {
+	all := []interface{}{node}
+	for _, c := range children(node) {
+		all = append(all, descendants(c)...)
+	}
+	return all
+}
+
+// ValueString returns the string representation of a JSON value, strings are
+// returned as is, other values as JSON.
+func ValueString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case nil:
+		return "null"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// Decode decodes data into a value that can be passed to Select, numbers are
+// kept as json.Number to preserve their representation.
+func Decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
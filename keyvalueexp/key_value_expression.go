@@ -76,6 +76,36 @@ func (ke *KeyValueExpr) ContainedInMap(m map[string]string) bool {
 	return true
 }
 
+// Submatches will match the values of m against the regexp.Regexp of their key, and
+// return the named groups of all matches, mapped by key and group name. Keys without
+// a value in m, values that does not match, and regexp.Regexp without named groups
+// are left out.
+// ex.
+// {"Authorization": "^Bearer (?P<token>.+)$"} returns {"Authorization": {"token": "..."}}
+func (ke *KeyValueExpr) Submatches(m map[string]string) map[string]map[string]string {
+	submatches := make(map[string]map[string]string)
+	for k, matcher := range ke.paramMatchers {
+		v, ok := m[k]
+		if !ok {
+			continue
+		}
+		matches := matcher.FindStringSubmatch(v)
+		if matches == nil {
+			continue
+		}
+		groups := make(map[string]string)
+		for i, name := range matcher.SubexpNames() {
+			if name != "" {
+				groups[name] = matches[i]
+			}
+		}
+		if len(groups) > 0 {
+			submatches[k] = groups
+		}
+	}
+	return submatches
+}
+
 // MatcherCount will return the number of regexp.Regexp in the Matcher.
 func (ke *KeyValueExpr) MatcherCount() int {
 	return len(ke.paramMatchers)
@@ -2,9 +2,10 @@ package mockhttp
 
 import (
 	"fmt"
+	"github.com/thorsager/mockdev/openapi"
 	"github.com/thorsager/mockdev/util"
 	"gopkg.in/yaml.v2"
-	"os"
+	"io/ioutil"
 	"path/filepath"
 	"strings"
 )
@@ -19,7 +20,13 @@ type Configuration struct {
 	BindAddr          string   `yaml:"bind-addr"`
 	ConversationFiles []string `yaml:"conversation-files"`
 	Conversations     []Conversation
-	Logging           SessionLogging `yaml:"session-logging"`
+	Logging           SessionLogging            `yaml:"session-logging"`
+	JournalSize       int                       `yaml:"journal-size,omitempty"` // default DefaultJournalSize, negative disables
+	TLS               *TLSConfiguration         `yaml:"tls,omitempty"`          // serve HTTPS, if configured
+	RandomSeed        int64                     `yaml:"random-seed,omitempty"`  // seed for random responses and delays, 0 is time based
+	Unmatched         *UnmatchedResponse        `yaml:"unmatched,omitempty"`    // served if no conversation matches, 418 if not set
+	Passthrough       *PassthroughConfiguration `yaml:"passthrough,omitempty"`  // proxy requests no conversation matches, instead of Unmatched
+	VirtualHosts      []VirtualHost             `yaml:"virtual-hosts,omitempty"`
 }
 
 type SessionLogging struct {
@@ -34,6 +41,23 @@ type Conversation struct {
 	Request     Request  `yaml:"request"`
 	Response    Response `yaml:"response"`
 	AfterScript []string `yaml:"after-script"`
+	// Responses, if set, are served instead of Response, in the order decided by
+	// ResponseMode, possible: "sequence" (default), "cycle", "random".
+	Responses    []Response `yaml:"responses,omitempty"`
+	ResponseMode string     `yaml:"response-mode,omitempty"`
+	// Scenario, RequiredState and NewState makes the conversation stateful, it will
+	// only match when Scenario is in RequiredState, and will move the Scenario to
+	// NewState when served. All scenarios start out in ScenarioStarted.
+	Scenario      string `yaml:"scenario,omitempty"`
+	RequiredState string `yaml:"required-state,omitempty"`
+	NewState      string `yaml:"new-state,omitempty"`
+	// WebSocket, if set, makes the conversation only match WebSocket upgrade
+	// requests, which are upgraded instead of being served Response.
+	WebSocket *WebSocket `yaml:"websocket,omitempty"`
+}
+
+func (c Conversation) IsStateful() bool {
+	return c.RequiredState != "" || c.NewState != ""
 }
 
 func (c Conversation) IsBreaking() bool {
@@ -47,11 +71,22 @@ func (c Conversation) BreakOnNoMatch() bool {
 }
 
 type Request struct {
+	// HostMatcher is a regular expression, that must match the host of the
+	// request, without port and in lower case.
+	HostMatcher     string     `yaml:"host-matcher,omitempty"`
 	UrlMatcher      UrlMatcher `yaml:"url-matcher"`
 	MethodMatcher   string     `yaml:"method-matcher"`
 	HeaderMatchType string     `yaml:"header-match-type"` // possible "", "contains", "if-present"(default)
 	HeaderMatchers  []string   `yaml:"header-matchers,omitempty"`
 	BodyMatcher     string     `yaml:"body-matcher,omitempty"`
+	// JsonMatchers maps JSONPath expressions to regular expressions, or to literal
+	// values prefixed by "==", that must all match a value of the JSON body.
+	JsonMatchers map[string]string `yaml:"json-matchers,omitempty"`
+	// XmlMatchers maps XPath expressions to regular expressions, or to literal
+	// values prefixed by "==", that must all match a value of the XML body. Prefixes
+	// used in the expressions are mapped to namespace URIs by XmlNamespaces.
+	XmlMatchers   map[string]string `yaml:"xml-matchers,omitempty"`
+	XmlNamespaces map[string]string `yaml:"xml-namespaces,omitempty"`
 }
 
 func (r Request) GetHeaderMatchType() string {
@@ -68,29 +103,80 @@ type Response struct {
 	Headers    []string      `yaml:"headers"`
 	Body       string        `yaml:"body"`
 	BodyFile   string        `yaml:"body-file,omitempty"`
-	Delay      ResponseDelay `yaml:"delay,omitempty"`
+	RawBody    bool          `yaml:"raw-body,omitempty"`   // body is served as is, not as a template
+	Delay      ResponseDelay `yaml:"delay,omitempty"`      // before headers are sent
+	BodyDelay  ResponseDelay `yaml:"body-delay,omitempty"` // after headers are sent, before the body
 	Script     []string      `yaml:"script,omitempty"`
+	Weight     int           `yaml:"weight,omitempty"` // chance of being picked by response-mode "random", default 1
+	Fault      *Fault        `yaml:"fault,omitempty"`  // make the response fail, not used by scripted responses
+	Chunks     []Chunk       `yaml:"chunks,omitempty"` // streamed instead of body
+	SSE        *EventStream  `yaml:"sse,omitempty"`    // Server-Sent Events streamed instead of body
 }
 
-type ResponseDelay struct {
-	Max int `yaml:"max,omitempty"`
-	Min int `yaml:"min,omitempty"`
+func (r Response) GetWeight() int {
+	if r.Weight == 0 {
+		return 1
+	}
+	return r.Weight
 }
 
 type UrlMatcher struct {
-	Path            string `yaml:"path,omitempty"`
+	Path string `yaml:"path,omitempty"`
+	// PathTemplate matches the path by a template, ex. '/users/{id:[0-9]+}/ports/{port}',
+	// instead of by the regular expression Path.
+	PathTemplate    string `yaml:"path-template,omitempty"`
 	Query           string `yaml:"query,omitempty"`
 	QueryLooseMatch bool   `yaml:"query-loose-match"`
 }
 
+// LoadConversations returns the conversations of the configuration, the
+// conversations found in its conversation-files, and the conversations of its
+// virtual hosts, sorted by match-order.
+func (c *Configuration) LoadConversations() ([]Conversation, error) {
+	conversations := append([]Conversation(nil), c.Conversations...)
+	for _, cf := range c.ConversationFiles {
+		con, err := DecodeConversationFile(cf)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, con...)
+	}
+	for _, v := range c.VirtualHosts {
+		con, err := v.LoadConversations()
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, con...)
+	}
+	SortConversations(conversations)
+	return conversations, nil
+}
+
+// DecodeConversationFile returns the conversations of a conversation file, if
+// the file is an OpenAPI 3 document, or an HTTP Archive, conversations are
+// created from its operations or entries, see ConversationsFromOpenAPI and
+// ConversationsFromHAR.
 func DecodeConversationFile(filename string) ([]Conversation, error) {
-	cff, err := os.Open(filename)
+	data, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("unable to load file '%s':, %w", filename, err)
 	}
-	defer func() { _ = cff.Close() }()
+	if openapi.IsOpenAPI(data) {
+		vl, err := ConversationsFromOpenAPI(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert OpenAPI document '%s': %w", filename, err)
+		}
+		return vl, nil
+	}
+	if isHARFile(filename, data) {
+		vl, err := ConversationsFromHAR(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert HTTP Archive '%s': %w", filename, err)
+		}
+		return vl, nil
+	}
 	var vl []Conversation
-	err = yaml.NewDecoder(cff).Decode(&vl)
+	err = yaml.Unmarshal(data, &vl)
 	if err != nil {
 		return nil, fmt.Errorf("unable to decode conversation in file '%s': %w", filename, err)
 	}
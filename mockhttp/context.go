@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"github.com/thorsager/mockdev/logging"
-	"sort"
 )
 
 type conversationScores struct {
@@ -14,6 +13,7 @@ type scoreMap map[string]int
 type sessionIdKey struct{}
 type scoreKey struct{}
 type logKey struct{}
+type requestBodyKey struct{}
 
 func contextWithWithSessionId(id int) context.Context {
 	ctx := context.Background()
@@ -35,6 +35,17 @@ func getContextLogger(ctx context.Context) (logging.Logger, error) {
 	}
 }
 
+func contextWithRequestBody(ctx context.Context, body []byte) context.Context {
+	return context.WithValue(ctx, requestBodyKey{}, body)
+}
+
+func getRequestBody(ctx context.Context) []byte {
+	if body, ok := ctx.Value(requestBodyKey{}).([]byte); ok {
+		return body
+	}
+	return nil
+}
+
 func getSessionId(ctx context.Context) (int, error) {
 	return getContextValueAsInt(ctx, sessionIdKey{})
 }
@@ -75,26 +86,27 @@ func (s *conversationScores) inc(name string) {
 	s.bump(name, 1)
 }
 
-func (s *conversationScores) tieBreak(candidates []Conversation) (Conversation, error) {
-	type kv struct {
-		k string
-		v int
-	}
-	var ss []kv
-	for k, v := range s.values {
-		if c, found := lookupByName(candidates, k); found && !c.IsBreaking() {
-			ss = append(ss, kv{k, v - (c.Order * 100)})
+func (s *conversationScores) reset() {
+	s.values = make(scoreMap)
+}
+
+// tieBreak returns the candidate with the highest score, less 100 for each step
+// of match-order, candidates earlier in match-order win ties.
+func (s *conversationScores) tieBreak(candidates []*compiledConversation) (compiledConversation, error) {
+	var theOne *compiledConversation
+	best := 0
+	for _, c := range candidates {
+		if c.IsBreaking() {
+			continue
+		}
+		if v := s.values[c.Name] - (c.Order * 100); theOne == nil || v > best {
+			theOne, best = c, v
 		}
 	}
-	sort.Slice(ss, func(i, j int) bool {
-		return ss[i].v > ss[j].v
-	})
-
-	if theOne, found := lookupByName(candidates, ss[0].k); found {
-		return theOne, nil
-	} else {
-		return Conversation{}, fmt.Errorf("that is wierd, no candidates found in score")
+	if theOne == nil {
+		return compiledConversation{}, fmt.Errorf("that is wierd, no candidates found in score")
 	}
+	return *theOne, nil
 }
 
 func lookupByName(haystack []Conversation, needle string) (Conversation, bool) {
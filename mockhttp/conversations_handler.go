@@ -4,30 +4,43 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"github.com/thorsager/mockdev/jsonexp"
 	"github.com/thorsager/mockdev/logging"
 	"github.com/thorsager/mockdev/rawhttp"
 	"github.com/thorsager/mockdev/scripts"
+	"github.com/thorsager/mockdev/xmlexp"
 	"io"
 	"io/ioutil"
-	"math/rand"
 	"net/http"
 	"os"
 	"path"
-	"regexp"
+	"sort"
 	"strings"
 	"sync"
-	"text/template"
+	"sync/atomic"
 	"time"
 )
 
 type ConversationsHandler struct {
 	sync.Mutex
-	Conversations      []Conversation
+	Conversations      []Conversation // served, loaded and added merged by match-order
+	loaded             []Conversation // loaded from the configuration
+	added              []Conversation // added at runtime
 	Log                logging.Logger
 	SessionLogLocation string
 	SessionLogReceived bool
 	sessionCounter     int
 	BindAddress        string
+	JournalSize        int      // number of requests kept in journal, negative disables
+	RandomSeed         int64    // seed for random responses and delays, 0 is time based
+	LatencyMultiplier  *float64 // scales all delays, 1 if not set
+	scenarios          scenarioStates
+	sequences          map[string]int
+	random             *lockedRand
+	journal            journal
+	Unmatched          *UnmatchedResponse                // served if no conversation matches, 418 if not set
+	Passthrough        http.Handler                      // serves requests no conversation matches instead of Unmatched, if set
+	index              atomic.Pointer[conversationIndex] // replaced, not changed, when conversations change
 }
 
 func (h *ConversationsHandler) sessionContext() context.Context {
@@ -35,8 +48,39 @@ func (h *ConversationsHandler) sessionContext() context.Context {
 	defer h.Unlock()
 	h.sessionCounter = h.sessionCounter + 1
 	ctx := contextWithWithSessionId(h.sessionCounter)
-	setContextLogger(ctx, h.Log)
-	return ctx
+	return setContextLogger(ctx, h.Log)
+}
+
+// ResetSessions will reset the session counter, making the next session id 1.
+func (h *ConversationsHandler) ResetSessions() {
+	h.Lock()
+	defer h.Unlock()
+	h.sessionCounter = 0
+}
+
+// SessionLogs returns the ids of all sessions found in the session-log location.
+func (h *ConversationsHandler) SessionLogs() ([]int, error) {
+	entries, err := ioutil.ReadDir(h.SessionLogLocation)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ids []int
+	for _, e := range entries {
+		var id int
+		if _, err := fmt.Sscanf(e.Name(), "sess_%d.log", &id); err == nil && !e.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// ReadSessionLog returns the content of the session-log of session sesId.
+func (h *ConversationsHandler) ReadSessionLog(sesId int) ([]byte, error) {
+	return ioutil.ReadFile(h.sessionFilename(sesId))
 }
 
 func (h *ConversationsHandler) sessionFilename(sesId int) string {
@@ -107,80 +151,121 @@ func (h *ConversationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	_ = r.Body.Close() //  must close
 	r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
 
+	setClientCertHeaders(r)
+
 	h.Log.Tracef("Request %s %s", r.Method, r.URL)
 	h.Log.Tracef("%s", bodyBytes)
 
-	var theOne Conversation
-	candidates, breaker := h.filterConversations(ctx, r)
-	if breaker != nil {
-		theOne = *breaker
-		h.Log.Debugf("Breaking match on: %s", theOne.Name)
-	} else {
-		if len(candidates) < 1 {
-			http.Error(w, "I'm not a teapot", 418)
-			h.Log.Warnf("No matches after conversation-filter: %s \n%s", r.URL.Path, string(bodyBytes))
-			return
-		}
-		score, _ := getConversationScores(ctx)
-		h.Log.Debugf("scoreKey: %#v", score)
-		theOne, err = score.tieBreak(candidates)
-		if err != nil {
-			http.Error(w, "I'm not a teapot", 418)
-			h.Log.Warnf("No matches after conversation-scoring: %s \n%s", r.URL.Path, string(bodyBytes))
-			return
-		}
+	sesId, _ := getSessionId(ctx)
+	entry := JournalEntry{
+		Time:       time.Now(),
+		SessionId:  sesId,
+		RemoteAddr: r.RemoteAddr,
+		TLS:        r.TLS != nil,
+		Host:       r.Host,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      r.URL.RawQuery,
+		Headers:    r.Header.Clone(),
+		Body:       journalBody(bodyBytes),
+		BodySize:   len(bodyBytes),
+	}
+
+	capture := &responseCapture{ResponseWriter: w}
+	w = capture
+	var seq int
+	defer func() { h.completeRecord(seq, capture) }()
+
+	theOne, found := h.claimConversation(ctx, r, bodyBytes)
+	if !found && h.Passthrough != nil {
+		entry.Proxied = true
+		seq = h.record(entry)
+		h.Log.Debugf("No matching conversation, passing through: %s %s", r.Method, r.URL)
+		h.Passthrough.ServeHTTP(w, r)
+		return
 	}
+	if !found {
+		seq = h.record(entry)
+		h.serveUnmatched(w, r, bodyBytes)
+		h.Log.Warnf("No matching conversation: %s \n%s", r.URL.Path, string(bodyBytes))
+		return
+	}
+	entry.Matched = true
+	entry.Conversation = theOne.Name
+	seq = h.record(entry)
+	theOne.Response = h.nextResponse(theOne.Conversation)
 
-	if err := handleDelay(theOne.Response.Delay); err != nil {
+	if err := h.handleDelay(theOne.Response.Delay); err != nil {
 		h.Log.Errorf("While handling response-delay: %v", err)
 	}
 
 	_ = h.logRequestBody(ctx, bytes.NewBuffer(bodyBytes))
-	_ = h.serveResponse(w, r, theOne)
+	if theOne.WebSocket != nil {
+		if err := h.serveWebSocket(ctx, w, r, theOne); err != nil {
+			h.Log.Errorf("While serving websocket: %v", err)
+		}
+	} else {
+		_ = h.serveResponse(w, r, theOne)
+	}
 }
 
-func handleDelay(delay ResponseDelay) error {
-	if delay.Max == 0 && delay.Min == 0 || os.Getenv("IGNORE_DELAY") != "" {
-		return nil // no delay
+// claimConversation selects the conversation to serve r, and moves its scenario
+// to the new state of the conversation. If the scenario is moved by another
+// request, after the conversation was selected, the conversation is selected
+// again.
+func (h *ConversationsHandler) claimConversation(ctx context.Context, r *http.Request, bodyBytes []byte) (compiledConversation, bool) {
+	for {
+		theOne, found := h.selectConversation(ctx, r, bodyBytes)
+		if !found || h.transitionScenario(theOne.Conversation) {
+			return theOne, found
+		}
+		h.Log.Debugf("Scenario '%s' left state '%s', selecting again", theOne.Scenario, theOne.RequiredState)
+		score, _ := getConversationScores(ctx)
+		score.reset()
 	}
-	interval := 0
-	delta := delay.Max - delay.Min
-	if delta < 0 {
-		return fmt.Errorf("invalid sleep interval min=%d, max=%d", delay.Min, delay.Max)
+}
+
+// selectConversation returns the conversation to serve r, either a breaking
+// conversation, or the matching conversation with the highest score.
+func (h *ConversationsHandler) selectConversation(ctx context.Context, r *http.Request, bodyBytes []byte) (compiledConversation, bool) {
+	candidates, breaker := h.filterConversations(ctx, r, bodyBytes)
+	if breaker != nil {
+		h.Log.Debugf("Breaking match on: %s", breaker.Name)
+		return *breaker, true
 	}
-	if delta == 0 {
-		interval = delay.Min
-	} else {
-		interval = rand.Intn(delta)
+	if len(candidates) < 1 {
+		return compiledConversation{}, false
 	}
-	if interval > 0 {
-		time.Sleep(time.Duration(interval) * time.Millisecond)
+	score, _ := getConversationScores(ctx)
+	h.Log.Debugf("scoreKey: %#v", score)
+	theOne, err := score.tieBreak(candidates)
+	if err != nil {
+		h.Log.Debugf("%v", err)
+		return compiledConversation{}, false
 	}
-	return nil
+	return theOne, true
 }
 
-func (h *ConversationsHandler) filterConversations(ctx context.Context, r *http.Request) (candidates []Conversation, breaker *Conversation) {
-	for _, conversation := range h.Conversations {
+func (h *ConversationsHandler) filterConversations(ctx context.Context, r *http.Request, bodyBytes []byte) (candidates []*compiledConversation, breaker *compiledConversation) {
+	states := h.ScenarioStates()
+	body := &requestBody{raw: bodyBytes}
+	for _, conversation := range h.conversationIndex().candidates(r.Method, r.URL.Path) {
 		h.Log.Debugf("Matching [%d] '%s'", conversation.Order, conversation.Name)
-		methodMatch := matchMethod(ctx, r, conversation)
-		urlMatch := matchURL(ctx, r, conversation)
-		headersMatch := matchHeaders(ctx, r, conversation)
-		bodyMatch := matchBody(ctx, r, conversation)
-
-		allMatch := methodMatch && urlMatch && headersMatch && bodyMatch
+		result := matchConversation(ctx, states, r, body, conversation)
+		allMatch := result.all()
 
 		if conversation.BreakOnMatch() && allMatch {
 			h.Log.Debugf("Breaking on 'match' '%s'", conversation.Name)
-			return nil, &conversation
+			return nil, conversation
 		} else if conversation.BreakOnNoMatch() && !allMatch {
 			h.Log.Debugf("Breaking on 'no-match' '%s'", conversation.Name)
-			return nil, &conversation
+			return nil, conversation
 		}
 		if allMatch {
 			h.Log.Debugf("Matching all '%s'", conversation.Name)
 			candidates = append(candidates, conversation)
 		} else {
-			h.Log.Tracef("Disregarding '%s' methodMatch=%t, urlMatch=%t, headerMatch=%t, bodyMatch=%t", conversation.Name, methodMatch, urlMatch, headersMatch, bodyMatch)
+			h.Log.Tracef("Disregarding '%s' %s", conversation.Name, result)
 		}
 	}
 	return candidates, nil
@@ -203,45 +288,107 @@ func (h *ConversationsHandler) createBaseTemplateData() map[string]interface{} {
 	return td
 }
 
-func (h *ConversationsHandler) serveResponse(w http.ResponseWriter, r *http.Request, conversation Conversation) error {
-
+// createTemplateData returns the base template data, along with the match-groups
+// and values extracted from r by the request matchers of conversation.
+func (h *ConversationsHandler) createTemplateData(r *http.Request, conversation compiledConversation) (map[string]interface{}, error) {
 	templateVars := h.createBaseTemplateData()
+	if tlsData := createTLSData(r); tlsData != nil {
+		templateVars[tlsInfo] = tlsData
+	}
+
+	// get body and re-install
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = r.Body.Close() //  must close
+	r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
+	templateVars[requestInfo] = createRequestData(r, bodyBytes)
 
-	if conversation.Request.UrlMatcher.Path != "" {
-		m := regexp.MustCompile(conversation.Request.UrlMatcher.Path)
+	if m := conversation.matchers.host; m != nil {
+		setNamedGroups(templateVars, hostValues, namedGroups(m, m.FindStringSubmatch(requestHost(r.Host))))
+	}
+
+	if m := conversation.matchers.path; m != nil {
 		matches := m.FindStringSubmatch(r.URL.Path)
 		for i, j := range matches {
 			h.Log.Debugf("p%d=%s", i, j)
 			templateVars[fmt.Sprintf("p%d", i)] = j
 		}
+		setNamedGroups(templateVars, pathValues, namedGroups(m, matches))
 	}
 
-	if conversation.Request.UrlMatcher.Query != "" {
-		m := regexp.MustCompile(conversation.Request.UrlMatcher.Query)
+	if m := conversation.matchers.rawQuery; m != nil {
 		matches := m.FindStringSubmatch(r.URL.RawQuery)
 		for i, j := range matches {
 			templateVars[fmt.Sprintf("q%d", i)] = j
 		}
+		groups := make(map[string]string)
+		for _, g := range conversation.matchers.query.SubmatchQuery(r.URL.Query()) {
+			for name, v := range g {
+				groups[name] = v
+			}
+		}
+		setNamedGroups(templateVars, queryValues, groups)
 	}
 
-	if conversation.Request.BodyMatcher != "" {
-		// get body and re-install
-		bodyBytes, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			return err
+	if conversation.matchers.headers != nil {
+		submatches := conversation.matchers.headers.SubmatchHeader(r.Header)
+		if len(submatches) > 0 {
+			headers := make(map[string]map[string]string)
+			for header, groups := range submatches {
+				headers[header] = groups
+				for name, v := range groups {
+					templateVars[matchVarName(headerValues, header+"_"+name)] = v
+				}
+			}
+			templateVars[headerValues] = headers
 		}
-		_ = r.Body.Close() //  must close
-		r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
+	}
 
-		m := regexp.MustCompile(conversation.Request.BodyMatcher)
+	if m := conversation.matchers.body; m != nil {
 		matches := m.FindSubmatch(bodyBytes)
+		groups := make(map[string]string)
 		for i, j := range matches {
 			h.Log.Tracef("match-group %d = '%s'", i, string(j))
 			templateVars[fmt.Sprintf("b%d", i)] = string(j)
+			if name := m.SubexpNames()[i]; name != "" {
+				groups[name] = string(j)
+			}
+		}
+		setNamedGroups(templateVars, bodyValues, groups)
+	}
+
+	if conversation.matchers.json != nil {
+		if doc, err := jsonexp.Decode(bodyBytes); err == nil {
+			values, _ := conversation.matchers.json.Extract(doc)
+			templateVars[jsonValues] = values
+			for path, v := range values {
+				h.Log.Tracef("json-match %s = '%s'", path, v)
+				templateVars[matchVarName(jsonValues, path)] = v
+			}
+		}
+	}
+
+	if conversation.matchers.xml != nil {
+		if doc, err := xmlexp.Decode(bodyBytes); err == nil {
+			values, _ := conversation.matchers.xml.Extract(doc)
+			templateVars[xmlValues] = values
+			for path, v := range values {
+				h.Log.Tracef("xml-match %s = '%s'", path, v)
+				templateVars[matchVarName(xmlValues, path)] = v
+			}
 		}
 	}
-	// TODO: Figure out how match-groups could be implemented on Header Matchers.
 	h.Log.Tracef("templateVars: %+v", templateVars)
+	return templateVars, nil
+}
+
+func (h *ConversationsHandler) serveResponse(w http.ResponseWriter, r *http.Request, conversation compiledConversation) error {
+	templateVars, err := h.createTemplateData(r, conversation)
+	if err != nil {
+		return err
+	}
 
 	if len(conversation.Response.Script) > 0 {
 		resp := h.executeScript(conversation.Response.Script, templateVars)
@@ -269,7 +416,7 @@ func (h *ConversationsHandler) serveResponse(w http.ResponseWriter, r *http.Requ
 		// parse headers as templates
 		executedBuffer := &bytes.Buffer{}
 		for _, s := range conversation.Response.Headers {
-			tmpl, err := template.New("body").Parse(s)
+			tmpl, err := h.template("header", s)
 			if err != nil {
 				return err
 			}
@@ -278,21 +425,51 @@ func (h *ConversationsHandler) serveResponse(w http.ResponseWriter, r *http.Requ
 			executedBuffer.Reset()
 		}
 
-		// parse body as template
-		tmpl, err := template.New("body").Parse(bodyBuffer.String())
-		if err != nil {
-			return err
+		if conversation.Response.IsStreaming() {
+			w.Header().Add("X-Powered-By", "mockdev")
+			if err := h.serveStream(w, r, conversation.Response, templateVars); err != nil {
+				h.Log.Errorf("While streaming response: %v", err)
+				return err
+			}
+			h.Log.Infof("Streamed response from conversation: '%d:%s' (%s)", conversation.Order, conversation.Name, r.URL)
+			_ = h.executeScript(conversation.AfterScript, templateVars)
+			return nil
 		}
 
-		err = tmpl.Execute(executedBuffer, templateVars)
-		if err != nil {
-			return err
+		if conversation.Response.RawBody {
+			executedBuffer = bodyBuffer
+		} else {
+			// parse body as template
+			tmpl, err := h.template("body", bodyBuffer.String())
+			if err != nil {
+				return err
+			}
+
+			err = tmpl.Execute(executedBuffer, templateVars)
+			if err != nil {
+				return err
+			}
 		}
 
 		w.Header().Add("X-Powered-By", "mockdev")
 		w.Header().Add("size", fmt.Sprintf("%d", executedBuffer.Len()))
-		w.WriteHeader(conversation.Response.StatusCode)
-		_, _ = w.Write(executedBuffer.Bytes())
+		if conversation.Response.Fault != nil {
+			if err := h.serveFault(w, conversation.Response.StatusCode, executedBuffer.Bytes(), *conversation.Response.Fault); err != nil {
+				h.Log.Errorf("While serving fault: %v", err)
+				return err
+			}
+		} else {
+			w.WriteHeader(conversation.Response.StatusCode)
+			if !conversation.Response.BodyDelay.IsZero() {
+				if f, ok := w.(http.Flusher); ok {
+					f.Flush()
+				}
+				if err := h.handleDelay(conversation.Response.BodyDelay); err != nil {
+					h.Log.Errorf("While handling response body-delay: %v", err)
+				}
+			}
+			_, _ = w.Write(executedBuffer.Bytes())
+		}
 	}
 
 	h.Log.Infof("Served response from conversation: '%d:%s' (%s)", conversation.Order, conversation.Name, r.URL)
@@ -344,5 +521,5 @@ func (h *ConversationsHandler) executeScript(script []string, templateVars map[s
 
 func addHeaderFromString(w http.ResponseWriter, s string) {
 	t := strings.SplitN(s, ":", 2)
-	w.Header().Add(t[0], t[1])
+	w.Header().Add(strings.TrimSpace(t[0]), strings.TrimSpace(t[1]))
 }
@@ -0,0 +1,205 @@
+package mockhttp
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const Fixed = "fixed"
+const Uniform = "uniform"
+const Normal = "normal"
+const LogNormal = "log-normal"
+const Percentiles = "percentiles"
+
+// Duration is a time.Duration, that is configured either as a number of
+// milliseconds, or as a duration string such as "250ms" or "1.5s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	parsed, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// ParseDuration parses s as a number of milliseconds, or as a time.Duration.
+func ParseDuration(s string) (Duration, error) {
+	s = strings.TrimSpace(s)
+	if ms, err := strconv.ParseFloat(s, 64); err == nil {
+		return Duration(ms * float64(time.Millisecond)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s'", s)
+	}
+	return Duration(d), nil
+}
+
+// ResponseDelay is a random delay, drawn from Distribution, which is possible:
+// "uniform" between Min and Max (default), "fixed" Mean, "normal" and
+// "log-normal" with Mean and StdDev, limited to Min and Max if set, and
+// "percentiles" interpolated between Percentiles. A delay may also be configured
+// as a single duration, which is a fixed delay.
+type ResponseDelay struct {
+	Distribution string               `yaml:"distribution,omitempty"`
+	Max          Duration             `yaml:"max,omitempty"`
+	Min          Duration             `yaml:"min,omitempty"`
+	Mean         Duration             `yaml:"mean,omitempty"`
+	StdDev       Duration             `yaml:"std-dev,omitempty"`
+	Percentiles  map[float64]Duration `yaml:"percentiles,omitempty"` // ex. {50: 100ms, 99: 2s}
+}
+
+func (d *ResponseDelay) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var fixed Duration
+	if err := unmarshal(&fixed); err == nil {
+		*d = ResponseDelay{Distribution: Fixed, Mean: fixed}
+		return nil
+	}
+	type plain ResponseDelay
+	return unmarshal((*plain)(d))
+}
+
+func (d ResponseDelay) IsZero() bool {
+	return d.Distribution == "" && d.Max == 0 && d.Min == 0 && d.Mean == 0 && d.StdDev == 0 && len(d.Percentiles) == 0
+}
+
+func (d ResponseDelay) GetDistribution() string {
+	if d.Distribution == "" {
+		if len(d.Percentiles) > 0 {
+			return Percentiles
+		}
+		return Uniform
+	}
+	return strings.ToLower(d.Distribution)
+}
+
+type randomSampler interface {
+	Float64() float64
+	NormFloat64() float64
+}
+
+// Sample draws a delay from the distribution.
+func (d ResponseDelay) Sample(rnd randomSampler) (time.Duration, error) {
+	var v float64
+	switch d.GetDistribution() {
+	case Fixed:
+		return time.Duration(d.Mean), nil
+	case Uniform:
+		if d.Max == 0 {
+			return time.Duration(d.Min), nil // no max, as for the other distributions
+		}
+		if d.Max < d.Min {
+			return 0, fmt.Errorf("invalid delay interval min=%s, max=%s", d.Min, d.Max)
+		}
+		v = float64(d.Min) + rnd.Float64()*float64(d.Max-d.Min)
+		return time.Duration(v), nil
+	case Normal:
+		v = float64(d.Mean) + rnd.NormFloat64()*float64(d.StdDev)
+	case LogNormal:
+		if d.Mean <= 0 {
+			return 0, fmt.Errorf("log-normal delay requires a positive mean")
+		}
+		// parameters of the underlying normal distribution, giving Mean and StdDev
+		m, s := float64(d.Mean), float64(d.StdDev)
+		sigma := math.Sqrt(math.Log(1 + (s*s)/(m*m)))
+		mu := math.Log(m) - sigma*sigma/2
+		v = math.Exp(mu + sigma*rnd.NormFloat64())
+	case Percentiles:
+		v = d.percentile(rnd.Float64() * 100)
+	default:
+		return 0, fmt.Errorf("invalid delay distribution '%s'", d.Distribution)
+	}
+	return d.limit(v), nil
+}
+
+// limit keeps v within Min and Max, if set, and never negative.
+func (d ResponseDelay) limit(v float64) time.Duration {
+	if v < float64(d.Min) {
+		v = float64(d.Min)
+	}
+	if d.Max > 0 && v > float64(d.Max) {
+		v = float64(d.Max)
+	}
+	if v < 0 {
+		v = 0
+	}
+	return time.Duration(v)
+}
+
+// percentile returns the delay at percentile p, interpolated linearly between
+// the configured percentiles, starting from Min at percentile 0, and ending at
+// Max at percentile 100, if set.
+func (d ResponseDelay) percentile(p float64) float64 {
+	type point struct{ p, v float64 }
+	points := []point{{0, float64(d.Min)}}
+	keys := make([]float64, 0, len(d.Percentiles))
+	for k := range d.Percentiles {
+		keys = append(keys, k)
+	}
+	sort.Float64s(keys)
+	for _, k := range keys {
+		points = append(points, point{k, float64(d.Percentiles[k])})
+	}
+	if d.Max > 0 && points[len(points)-1].p < 100 {
+		points = append(points, point{100, float64(d.Max)})
+	}
+	for i := 1; i < len(points); i++ {
+		if p <= points[i].p {
+			a, b := points[i-1], points[i]
+			if b.p == a.p {
+				return b.v
+			}
+			return a.v + (b.v-a.v)*(p-a.p)/(b.p-a.p)
+		}
+	}
+	return points[len(points)-1].v
+}
+
+// latencyMultiplier returns the factor all delays are scaled by, 0 if delays
+// are disabled by IGNORE_DELAY.
+func (h *ConversationsHandler) latencyMultiplier() float64 {
+	if os.Getenv("IGNORE_DELAY") != "" {
+		return 0
+	}
+	if h.LatencyMultiplier != nil {
+		return *h.LatencyMultiplier
+	}
+	return 1
+}
+
+// handleDelay sleeps for a delay drawn from the distribution of delay, scaled
+// by the latency multiplier.
+func (h *ConversationsHandler) handleDelay(delay ResponseDelay) error {
+	multiplier := h.latencyMultiplier()
+	if delay.IsZero() || multiplier <= 0 {
+		return nil // no delay
+	}
+	d, err := delay.Sample(h.randomSource())
+	if err != nil {
+		return err
+	}
+	d = time.Duration(float64(d) * multiplier)
+	if d > 0 {
+		time.Sleep(d)
+	}
+	return nil
+}
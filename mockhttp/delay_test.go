@@ -0,0 +1,174 @@
+package mockhttp
+
+import (
+	"gopkg.in/yaml.v2"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fixedSampler returns the same values every time, F by Float64 and N by
+// NormFloat64.
+type fixedSampler struct {
+	F, N float64
+}
+
+func (s fixedSampler) Float64() float64     { return s.F }
+func (s fixedSampler) NormFloat64() float64 { return s.N }
+
+func ms(v float64) Duration {
+	return Duration(v * float64(time.Millisecond))
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Duration
+		wantErr bool
+	}{
+		{"250", ms(250), false},
+		{"1.5", ms(1.5), false},
+		{" 250 ", ms(250), false},
+		{"250ms", ms(250), false},
+		{"1.5s", ms(1500), false},
+		{"1m", ms(60000), false},
+		{"", 0, true},
+		{"fast", 0, true},
+		{"250 ms", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseDuration(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDuration() error %v, expected error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDuration() %s, expected %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseDelay_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    ResponseDelay
+		wantErr bool
+	}{
+		{"milliseconds", "250", ResponseDelay{Distribution: Fixed, Mean: ms(250)}, false},
+		{"duration", "1.5s", ResponseDelay{Distribution: Fixed, Mean: ms(1500)}, false},
+		{"uniform", "{min: 100, max: 200ms}", ResponseDelay{Min: ms(100), Max: ms(200)}, false},
+		{"normal", "{distribution: normal, mean: 1s, std-dev: 100}", ResponseDelay{Distribution: Normal, Mean: ms(1000), StdDev: ms(100)}, false},
+		{"percentiles", "{percentiles: {50: 100ms, 99.9: 2s}, max: 5s}",
+			ResponseDelay{Max: ms(5000), Percentiles: map[float64]Duration{50: ms(100), 99.9: ms(2000)}}, false},
+		{"percentiles in milliseconds", "{percentiles: {50: 100, 90: 250}}",
+			ResponseDelay{Percentiles: map[float64]Duration{50: ms(100), 90: ms(250)}}, false},
+		{"invalid percentile", "{percentiles: {p50: 100ms}}", ResponseDelay{}, true},
+		{"invalid duration", "{percentiles: {50: fast}}", ResponseDelay{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ResponseDelay
+			err := yaml.Unmarshal([]byte(tt.yaml), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error %v, expected error %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() %+v, expected %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseDelay_Sample(t *testing.T) {
+	percentiles := map[float64]Duration{50: ms(100), 90: ms(200)}
+	tests := []struct {
+		name    string
+		delay   ResponseDelay
+		rnd     fixedSampler
+		want    Duration
+		wantErr bool
+	}{
+		{"fixed", ResponseDelay{Distribution: Fixed, Mean: ms(100)}, fixedSampler{0.9, 3}, ms(100), false},
+		{"uniform", ResponseDelay{Min: ms(100), Max: ms(200)}, fixedSampler{F: 0.25}, ms(125), false},
+		{"uniform min", ResponseDelay{Min: ms(100), Max: ms(200)}, fixedSampler{F: 0}, ms(100), false},
+		{"uniform interval", ResponseDelay{Min: ms(200), Max: ms(100)}, fixedSampler{}, 0, true},
+		{"uniform without max", ResponseDelay{Min: ms(200)}, fixedSampler{F: 0.5}, ms(200), false},
+		{"normal", ResponseDelay{Distribution: Normal, Mean: ms(100), StdDev: ms(10)}, fixedSampler{N: 2}, ms(120), false},
+		{"normal not negative", ResponseDelay{Distribution: Normal, Mean: ms(100), StdDev: ms(10)}, fixedSampler{N: -20}, 0, false},
+		{"normal min", ResponseDelay{Distribution: Normal, Mean: ms(100), StdDev: ms(10), Min: ms(90)}, fixedSampler{N: -2}, ms(90), false},
+		{"normal max", ResponseDelay{Distribution: Normal, Mean: ms(100), StdDev: ms(10), Max: ms(110)}, fixedSampler{N: 2}, ms(110), false},
+		{"log-normal", ResponseDelay{Distribution: LogNormal, Mean: ms(100)}, fixedSampler{N: 3}, ms(100), false},
+		{"log-normal spread", ResponseDelay{Distribution: LogNormal, Mean: ms(100), StdDev: ms(100)}, fixedSampler{N: 1},
+			Duration(math.Exp(math.Log(1e8) - math.Log(2)/2 + math.Sqrt(math.Log(2)))), false},
+		{"log-normal mean", ResponseDelay{Distribution: LogNormal, StdDev: ms(10)}, fixedSampler{}, 0, true},
+		{"percentiles from min", ResponseDelay{Percentiles: percentiles}, fixedSampler{F: 0.25}, ms(50), false},
+		{"percentiles between", ResponseDelay{Percentiles: percentiles}, fixedSampler{F: 0.7}, ms(150), false},
+		{"percentiles at", ResponseDelay{Percentiles: percentiles}, fixedSampler{F: 0.9}, ms(200), false},
+		{"percentiles above", ResponseDelay{Percentiles: percentiles}, fixedSampler{F: 0.95}, ms(200), false},
+		{"percentiles to max", ResponseDelay{Percentiles: percentiles, Max: ms(300)}, fixedSampler{F: 0.95}, ms(250), false},
+		{"percentiles from min set", ResponseDelay{Percentiles: percentiles, Min: ms(50)}, fixedSampler{F: 0.25}, ms(75), false},
+		{"unknown", ResponseDelay{Distribution: "poisson"}, fixedSampler{}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.delay.Sample(tt.rnd)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Sample() error %v, expected error %t", err, tt.wantErr)
+			}
+			if diff := got - time.Duration(tt.want); diff < -time.Microsecond || diff > time.Microsecond {
+				t.Errorf("Sample() %s, expected %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestResponseDelay_SampleSeeded checks the mean of delays drawn from the seeded
+// random source of a handler.
+func TestResponseDelay_SampleSeeded(t *testing.T) {
+	tests := []struct {
+		name  string
+		delay ResponseDelay
+		want  Duration // mean
+	}{
+		{"uniform", ResponseDelay{Min: ms(100), Max: ms(300)}, ms(200)},
+		{"normal", ResponseDelay{Distribution: Normal, Mean: ms(200), StdDev: ms(20)}, ms(200)},
+		{"log-normal", ResponseDelay{Distribution: LogNormal, Mean: ms(200), StdDev: ms(100)}, ms(200)},
+		{"percentiles", ResponseDelay{Percentiles: map[float64]Duration{50: ms(200), 100: ms(400)}}, ms(200)},
+	}
+	const samples = 10000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, other := newTestHandler(), newTestHandler()
+			var sum float64
+			for i := 0; i < samples; i++ {
+				d, err := tt.delay.Sample(h.randomSource())
+				if err != nil {
+					t.Fatalf("Sample() %v", err)
+				}
+				if o, _ := tt.delay.Sample(other.randomSource()); o != d {
+					t.Fatalf("Sample() %s, expected %s from the same seed", d, o)
+				}
+				sum += float64(d)
+			}
+			if mean := sum / samples; math.Abs(mean-float64(tt.want)) > 0.03*float64(tt.want) {
+				t.Errorf("mean %s, expected %s", time.Duration(mean), tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleDelay_LatencyMultiplier(t *testing.T) {
+	disabled := 0.0
+	h := newTestHandler()
+	h.LatencyMultiplier = &disabled
+	start := time.Now()
+	if err := h.handleDelay(ResponseDelay{Distribution: Fixed, Mean: ms(1000)}); err != nil {
+		t.Fatalf("handleDelay() %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("handleDelay() slept %s with latency multiplier 0", elapsed)
+	}
+}
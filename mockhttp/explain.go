@@ -0,0 +1,134 @@
+package mockhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DefaultClosest is the number of closest conversations listed by an explanation,
+// if not configured.
+const DefaultClosest = 5
+
+// UnmatchedResponse is served when no conversation matches a request. If Explain
+// is set, the body is an Explanation listing the closest conversations, as YAML,
+// or as JSON if accepted by the client.
+type UnmatchedResponse struct {
+	StatusCode int    `yaml:"status-code,omitempty"` // default 418
+	Body       string `yaml:"body,omitempty"`        // default "I'm not a teapot"
+	Explain    bool   `yaml:"explain,omitempty"`
+	Closest    int    `yaml:"closest,omitempty"` // number of conversations listed, default DefaultClosest
+}
+
+// Explanation explains how a request is matched by the conversations of a handler.
+type Explanation struct {
+	Message string              `yaml:"message,omitempty" json:"message,omitempty"`
+	Host    string              `yaml:"host,omitempty" json:"host,omitempty"`
+	Method  string              `yaml:"method" json:"method"`
+	Path    string              `yaml:"path" json:"path"`
+	Query   string              `yaml:"query,omitempty" json:"query,omitempty"`
+	Served  string              `yaml:"served,omitempty" json:"served,omitempty"` // the conversation that is served, if any
+	Closest []ConversationMatch `yaml:"closest" json:"closest"`
+}
+
+// ConversationMatch is the result of matching a request against a conversation,
+// Failed lists the matchers that did not match: scenario, method, url, headers,
+// body or upgrade. Score is the score of the conversation, used to pick between
+// matching conversations.
+type ConversationMatch struct {
+	Name    string   `yaml:"name" json:"name"`
+	Order   int      `yaml:"match-order" json:"match-order"`
+	BreakOn string   `yaml:"break-on,omitempty" json:"break-on,omitempty"`
+	Matched bool     `yaml:"matched" json:"matched"`
+	Score   int      `yaml:"score" json:"score"`
+	Failed  []string `yaml:"failed,omitempty" json:"failed,omitempty"`
+}
+
+// Explain matches r against all conversations of the handler, without serving
+// it, and returns the conversation that would be served, and the closest
+// conversations, ordered by the number of matchers failed and their score.
+func (h *ConversationsHandler) Explain(r *http.Request, closest int) (Explanation, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return Explanation{}, err
+	}
+	_ = r.Body.Close() //  must close
+	r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	return h.explain(r, body, closest), nil
+}
+
+// explain matches r against all conversations, scoring them in a context of
+// their own, so the scores of the session serving r are not affected.
+func (h *ConversationsHandler) explain(r *http.Request, body []byte, closest int) Explanation {
+	if closest <= 0 {
+		closest = DefaultClosest
+	}
+	explanation := Explanation{Host: r.Host, Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery}
+	if theOne, found := h.selectConversation(setContextLogger(contextWithWithSessionId(0), h.Log), r, body); found {
+		explanation.Served = theOne.Name
+	}
+
+	ctx := setContextLogger(contextWithWithSessionId(0), h.Log)
+	score, _ := getConversationScores(ctx)
+	states := h.ScenarioStates()
+	reqBody := &requestBody{raw: body}
+	conversations := h.conversationIndex().conversations
+	matches := make([]ConversationMatch, len(conversations))
+	for i, c := range conversations {
+		result := matchConversation(ctx, states, r, reqBody, c)
+		matches[i] = ConversationMatch{Name: c.Name, Order: c.Order, BreakOn: c.BreakOn, Matched: result.all(), Failed: result.failed()}
+	}
+	for i := range matches {
+		matches[i].Score = score.values[matches[i].Name]
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if len(matches[i].Failed) != len(matches[j].Failed) {
+			return len(matches[i].Failed) < len(matches[j].Failed)
+		}
+		return matches[i].Score > matches[j].Score
+	})
+	if len(matches) > closest {
+		matches = matches[:closest]
+	}
+	explanation.Closest = matches
+	return explanation
+}
+
+// serveUnmatched serves the unmatched response of the handler.
+func (h *ConversationsHandler) serveUnmatched(w http.ResponseWriter, r *http.Request, body []byte) {
+	unmatched := UnmatchedResponse{}
+	if h.Unmatched != nil {
+		unmatched = *h.Unmatched
+	}
+	if unmatched.StatusCode == 0 {
+		unmatched.StatusCode = http.StatusTeapot
+	}
+	if unmatched.Body == "" {
+		unmatched.Body = "I'm not a teapot"
+	}
+	if !unmatched.Explain {
+		http.Error(w, unmatched.Body, unmatched.StatusCode)
+		return
+	}
+
+	explanation := h.explain(r, body, unmatched.Closest)
+	explanation.Message = unmatched.Body
+	data, err := yaml.Marshal(explanation)
+	contentType := "application/x-yaml"
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		data, err = json.MarshalIndent(explanation, "", "  ")
+		contentType = "application/json"
+	}
+	if err != nil {
+		h.Log.Errorf("While encoding explanation: %v", err)
+		http.Error(w, unmatched.Body, unmatched.StatusCode)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(unmatched.StatusCode)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,67 @@
+package mockhttp
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// explainTestConversations miss a GET /ports request without headers, on
+// nothing, on the method, on the headers, and on both.
+var explainTestConversations = []Conversation{
+	{Name: "get", Request: Request{MethodMatcher: "^GET$", UrlMatcher: UrlMatcher{Path: "^/ports$"}}},
+	{Name: "post", Request: Request{MethodMatcher: "^POST$", UrlMatcher: UrlMatcher{Path: "^/ports$"}}},
+	{Name: "accept", Request: Request{MethodMatcher: "^GET$", UrlMatcher: UrlMatcher{Path: "^/ports$"},
+		HeaderMatchers: []string{"Accept: ^application/json$"}}},
+	{Name: "post accept", Request: Request{MethodMatcher: "^POST$", UrlMatcher: UrlMatcher{Path: "^/ports$"},
+		HeaderMatchers: []string{"Accept: ^application/json$"}}},
+}
+
+func TestExplain(t *testing.T) {
+	h := newTestHandler(explainTestConversations...)
+	explanation, err := h.Explain(httptest.NewRequest("GET", "/ports?all=1", nil), 0)
+	if err != nil {
+		t.Fatalf("Explain() %v", err)
+	}
+	if explanation.Served != "get" || explanation.Method != "GET" || explanation.Path != "/ports" || explanation.Query != "all=1" {
+		t.Errorf("Explain() %+v, expected GET /ports served by get", explanation)
+	}
+	failed := make(map[string][]string)
+	var names []string
+	for _, m := range explanation.Closest {
+		failed[m.Name] = m.Failed
+		names = append(names, m.Name)
+		if m.Matched != (len(m.Failed) == 0) {
+			t.Errorf("%s matched %t, failed %v", m.Name, m.Matched, m.Failed)
+		}
+	}
+	want := map[string][]string{"get": nil, "post": {"method"}, "accept": {"headers"}, "post accept": {"method", "headers"}}
+	if !reflect.DeepEqual(failed, want) {
+		t.Errorf("failed %v, expected %v", failed, want)
+	}
+	if names[0] != "get" || names[3] != "post accept" {
+		t.Errorf("closest %v, expected ordered by the number of matchers failed", names)
+	}
+
+	if explanation, _ := h.Explain(httptest.NewRequest("GET", "/", nil), 2); len(explanation.Closest) != 2 {
+		t.Errorf("Explain() %d closest, expected 2", len(explanation.Closest))
+	}
+}
+
+func TestServeUnmatched_Explain(t *testing.T) {
+	h := newTestHandler(explainTestConversations[1:]...)
+	h.Unmatched = &UnmatchedResponse{StatusCode: 404, Body: "no such port", Explain: true, Closest: 1}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/ports", nil)
+	r.Header.Set("Accept", "application/json, text/plain") // not matched by accept
+	h.ServeHTTP(w, r)
+	if w.Code != 404 || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("served %d %s, expected 404 application/json", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, s := range []string{`"message": "no such port"`, `"failed": [`} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("served %s, expected it to contain %s", w.Body.String(), s)
+		}
+	}
+}
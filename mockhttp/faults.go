@@ -0,0 +1,157 @@
+package mockhttp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const FaultReset = "reset"
+const FaultEmpty = "empty"
+const FaultCloseAfter = "close-after"
+const FaultStall = "stall"
+const FaultDrip = "drip"
+const FaultMalformed = "malformed"
+
+// DefaultMalformedStatusLine is sent by the fault "malformed", if no status-line
+// is configured.
+const DefaultMalformedStatusLine = "HTTP/1.1 OK"
+
+// dripInterval is the interval between writes of the fault "drip".
+const dripInterval = 100 * time.Millisecond
+
+// Fault makes a response fail in a controlled way, so that the error handling of
+// clients can be tested.
+type Fault struct {
+	Type           string   `yaml:"type"`                       // possible: "reset", "empty", "close-after", "stall", "drip", "malformed"
+	Bytes          int      `yaml:"bytes,omitempty"`            // close-after: bytes of the body sent before closing
+	Stall          Duration `yaml:"stall,omitempty"`            // stall: time before closing, 0 waits for the client to close
+	BytesPerSecond int      `yaml:"bytes-per-second,omitempty"` // drip: rate at which the body is sent
+	StatusLine     string   `yaml:"status-line,omitempty"`      // malformed: sent instead of a valid status line
+}
+
+// serveFault writes the response, with status, the headers of w and body,
+// directly to the connection, failing as configured by the fault. If the
+// connection can not be taken over, 500 is served instead.
+func (h *ConversationsHandler) serveFault(w http.ResponseWriter, status int, body []byte, fault Fault) error {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		err := fmt.Errorf("fault '%s' requires a HTTP/1.x connection", fault.Type)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	conn, bufrw, err := hj.Hijack()
+	if err != nil {
+		err = fmt.Errorf("fault '%s': %v", fault.Type, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	h.Log.Debugf("serving fault '%s' to %s", fault.Type, conn.RemoteAddr())
+
+	switch strings.ToLower(fault.Type) {
+	case FaultReset:
+		return resetConnection(conn)
+	case FaultEmpty:
+		return nil
+	}
+
+	statusLine := fmt.Sprintf("HTTP/1.1 %03d %s", status, http.StatusText(status))
+	if strings.ToLower(fault.Type) == FaultMalformed {
+		statusLine = fault.StatusLine
+		if statusLine == "" {
+			statusLine = DefaultMalformedStatusLine
+		}
+	}
+	header := w.Header().Clone()
+	header.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	header.Set("Connection", "close")
+	if err := writeHead(bufrw.Writer, statusLine, header); err != nil {
+		return err
+	}
+
+	switch strings.ToLower(fault.Type) {
+	case FaultCloseAfter:
+		if fault.Bytes < len(body) {
+			body = body[:fault.Bytes]
+		}
+		_, err = bufrw.Write(body)
+		if err == nil {
+			err = bufrw.Flush()
+		}
+		return err
+	case FaultStall:
+		if fault.Stall > 0 {
+			time.Sleep(time.Duration(fault.Stall))
+		} else {
+			_, _ = io.Copy(ioutil.Discard, bufrw) // until the client closes
+		}
+		return nil
+	case FaultDrip:
+		return drip(bufrw.Writer, body, fault.BytesPerSecond)
+	default:
+		_, err = bufrw.Write(body)
+		if err == nil {
+			err = bufrw.Flush()
+		}
+		return err
+	}
+}
+
+func writeHead(w *bufio.Writer, statusLine string, header http.Header) error {
+	if _, err := w.WriteString(statusLine + "\r\n"); err != nil {
+		return err
+	}
+	if err := header.Write(w); err != nil {
+		return err
+	}
+	if _, err := w.WriteString("\r\n"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// drip writes body at bytesPerSecond, in writes every dripInterval.
+func drip(w *bufio.Writer, body []byte, bytesPerSecond int) error {
+	chunk := bytesPerSecond * int(dripInterval) / int(time.Second)
+	if chunk < 1 {
+		chunk = 1
+	}
+	interval := time.Duration(chunk) * time.Second / time.Duration(bytesPerSecond)
+	for len(body) > 0 {
+		n := chunk
+		if n > len(body) {
+			n = len(body)
+		}
+		if _, err := w.Write(body[:n]); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		body = body[n:]
+		if len(body) > 0 {
+			time.Sleep(interval)
+		}
+	}
+	return nil
+}
+
+// resetConnection closes conn, making the peer receive a TCP reset.
+func resetConnection(conn net.Conn) error {
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		if err := tcp.SetLinger(0); err != nil {
+			return err
+		}
+	}
+	return conn.Close()
+}
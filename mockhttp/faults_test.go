@@ -0,0 +1,48 @@
+package mockhttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFaultOverTLS(t *testing.T) {
+	h := newTestHandler(Conversation{
+		Name:    "close-after",
+		Request: Request{UrlMatcher: UrlMatcher{Path: "^/fault$"}},
+		Response: Response{
+			StatusCode: 200,
+			Body:       "hello world",
+			Fault:      &Fault{Type: FaultCloseAfter, Bytes: 5},
+		},
+	})
+	url := startTLSServer(t, h)
+
+	resp, err := tlsClient().Get(url + "/fault")
+	if err != nil {
+		t.Fatalf("Get() %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.ProtoMajor != 1 {
+		t.Errorf("proto = %s, want HTTP/1.x", resp.Proto)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err == nil {
+		t.Errorf("ReadAll() expected error, got body %q", body)
+	}
+	if string(body) != "hello" {
+		t.Errorf("body = %q, want %q", body, "hello")
+	}
+}
+
+func TestFaultNotHijackable(t *testing.T) {
+	h := newTestHandler()
+	w := httptest.NewRecorder()
+	if err := h.serveFault(w, 200, []byte("hello"), Fault{Type: FaultReset}); err == nil {
+		t.Errorf("serveFault() expected error")
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
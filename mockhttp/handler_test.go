@@ -0,0 +1,45 @@
+package mockhttp
+
+import (
+	"crypto/tls"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+)
+
+// newTestHandler returns a handler serving conversations, logging only errors.
+func newTestHandler(conversations ...Conversation) *ConversationsHandler {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	h := &ConversationsHandler{Log: logger, BindAddress: "127.0.0.1:0", RandomSeed: 1}
+	h.SetConversations(conversations)
+	return h
+}
+
+// startTLSServer serves h over HTTPS, the way mockdevd does, and returns the
+// base url.
+func startTLSServer(t *testing.T, h http.Handler) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() %v", err)
+	}
+	server := &http.Server{Handler: h}
+	config := &Configuration{TLS: &TLSConfiguration{}}
+	if err := config.ConfigureTLS(server); err != nil {
+		t.Fatalf("ConfigureTLS() %v", err)
+	}
+	go func() { _ = server.ServeTLS(l, "", "") }()
+	t.Cleanup(func() { _ = server.Close() })
+	return "https://" + l.Addr().String()
+}
+
+// tlsClient returns a client trusting any certificate, that prefers HTTP/2.
+func tlsClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+}
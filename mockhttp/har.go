@@ -0,0 +1,119 @@
+package mockhttp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/thorsager/mockdev/har"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// harCreator is the creator of HAR files exported from the journal.
+const harCreator = "mockdev"
+
+// ConversationsFromHAR turns each entry of an HTTP Archive into a conversation,
+// in the same way as they are recorded by a Recorder. Only the first of identical
+// requests is used, and all response bodies, also those that are not valid
+// UTF-8, are kept in the conversations, so no files are written.
+func ConversationsFromHAR(data []byte) ([]Conversation, error) {
+	archive, err := har.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	recorder := &Recorder{
+		MaxInlineBody: math.MaxInt32,
+		Deduplicate:   true,
+	}
+	for i, e := range archive.Log.Entries {
+		req, reqBody, err := e.HTTPRequest()
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %v", i, err)
+		}
+		resp, respBody, err := e.HTTPResponse()
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %v", i, err)
+		}
+		if resp.StatusCode == 0 {
+			continue // no response, ex. blocked or aborted by the browser
+		}
+		// the content of entries is decoded
+		resp.Header.Del("Content-Encoding")
+		if _, _, err := recorder.Record(req, reqBody, resp, respBody); err != nil {
+			return nil, fmt.Errorf("entry %d: %v", i, err)
+		}
+	}
+	return recorder.Conversations(), nil
+}
+
+// JournalHAR returns entries of the journal as an HTTP Archive, the URL of
+// requests is made absolute using the Host of the request, or the bind-address
+// of the handler.
+func (h *ConversationsHandler) JournalHAR(entries []JournalEntry) *har.HAR {
+	archive := har.New(harCreator, "")
+	for _, e := range entries {
+		u := url.URL{Scheme: "http", Host: e.Host, Path: e.Path, RawQuery: e.Query}
+		if e.TLS {
+			u.Scheme = "https"
+		}
+		if u.Host == "" {
+			u.Host = h.BindAddress
+		}
+		query, _ := url.ParseQuery(e.Query)
+
+		entry := har.Entry{
+			StartedDateTime: e.Time,
+			Time:            float64(e.Duration) / 1e6,
+			Timings:         har.Timings{Send: 0, Wait: float64(e.Duration) / 1e6, Receive: 0},
+			Comment:         e.Conversation,
+			Request: har.Request{
+				Method:      e.Method,
+				URL:         u.String(),
+				HTTPVersion: "HTTP/1.1",
+				Cookies:     []har.Cookie{},
+				Headers:     har.Headers(e.Headers),
+				QueryString: har.QueryString(query),
+				HeadersSize: -1,
+				BodySize:    e.BodySize,
+			},
+			Response: har.Response{
+				Status:      e.Status,
+				StatusText:  http.StatusText(e.Status),
+				HTTPVersion: "HTTP/1.1",
+				Cookies:     []har.Cookie{},
+				Headers:     har.Headers(e.ResponseHeaders),
+				RedirectURL: e.ResponseHeaders.Get("Location"),
+				HeadersSize: -1,
+				BodySize:    e.ResponseSize,
+				Content: har.Content{
+					Size:     e.ResponseSize,
+					MimeType: e.ResponseHeaders.Get("Content-Type"),
+				},
+			},
+		}
+		if e.Body != "" {
+			entry.Request.PostData = &har.PostData{MimeType: e.Headers.Get("Content-Type"), Text: e.Body}
+		}
+		if utf8.ValidString(e.ResponseBody) {
+			entry.Response.Content.Text = e.ResponseBody
+		} else {
+			entry.Response.Content.Text = base64.StdEncoding.EncodeToString([]byte(e.ResponseBody))
+			entry.Response.Content.Encoding = "base64"
+		}
+		if len(e.ResponseBody) < e.ResponseSize {
+			entry.Response.Content.Comment = fmt.Sprintf("truncated to %d bytes", len(e.ResponseBody))
+		}
+		if !e.Matched {
+			entry.Comment = "no conversation matched"
+		}
+		archive.Log.Entries = append(archive.Log.Entries, entry)
+	}
+	return archive
+}
+
+// isHARFile returns true if filename, with the content data, is an HTTP Archive.
+func isHARFile(filename string, data []byte) bool {
+	return strings.HasSuffix(strings.ToLower(filename), ".har") || har.IsHAR(data)
+}
@@ -0,0 +1,71 @@
+package mockhttp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+const binaryArchive = `{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "test", "version": "1"},
+    "entries": [{
+      "startedDateTime": "2023-05-01T10:00:00.000+02:00",
+      "time": 1,
+      "request": {
+        "method": "GET",
+        "url": "http://device.example.com/firmware",
+        "httpVersion": "HTTP/1.1",
+        "headers": [],
+        "queryString": [],
+        "headersSize": -1,
+        "bodySize": 0
+      },
+      "response": {
+        "status": 200,
+        "statusText": "OK",
+        "httpVersion": "HTTP/1.1",
+        "headers": [{"name": "Content-Type", "value": "application/octet-stream"}],
+        "content": {"size": 4, "mimeType": "application/octet-stream", "text": "AAEC/w==", "encoding": "base64"},
+        "redirectURL": "",
+        "headersSize": -1,
+        "bodySize": 4
+      },
+      "cache": {},
+      "timings": {"send": 0, "wait": 1, "receive": 0}
+    }]
+  }
+}`
+
+func TestDecodeConversationFile_HAR(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "device.har")
+	if err := ioutil.WriteFile(filename, []byte(binaryArchive), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conversations, err := DecodeConversationFile(filename)
+	if err != nil {
+		t.Fatalf("DecodeConversationFile() %v", err)
+	}
+	if len(conversations) != 1 {
+		t.Fatalf("DecodeConversationFile() %d conversations, expected 1", len(conversations))
+	}
+	want := []byte{0, 1, 2, 255}
+	response := conversations[0].Response
+	if response.BodyFile != "" || response.Body != string(want) || !response.RawBody {
+		t.Errorf("response body %q, body-file '%s', raw %t, expected raw body %q", response.Body, response.BodyFile, response.RawBody, want)
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("DecodeConversationFile() wrote %d files, expected none", len(entries)-1)
+	}
+
+	h := newTestHandler(conversations...)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://device.example.com/firmware", nil))
+	if !bytes.Equal(w.Body.Bytes(), want) {
+		t.Errorf("served %q, expected %q", w.Body.Bytes(), want)
+	}
+}
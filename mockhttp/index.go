@@ -0,0 +1,260 @@
+package mockhttp
+
+import (
+	"fmt"
+	"github.com/thorsager/mockdev/headerexp"
+	"github.com/thorsager/mockdev/jsonexp"
+	"github.com/thorsager/mockdev/queryexp"
+	"github.com/thorsager/mockdev/xmlexp"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"sync"
+	"text/template"
+)
+
+// indexMethods are the methods conversations are indexed by, requests using any
+// other method are matched against all conversations.
+var indexMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE"}
+
+// requestMatchers are the compiled request matchers of a conversation, nil if
+// the conversation does not use the matcher.
+type requestMatchers struct {
+	host     *regexp.Regexp
+	method   *regexp.Regexp
+	path     *regexp.Regexp
+	query    *queryexp.QueryExpr
+	rawQuery *regexp.Regexp // query as a regular expression of the raw query, for q<n>
+	headers  *headerexp.HeaderExpr
+	body     *regexp.Regexp
+	json     *jsonexp.JsonExpr
+	xml      *xmlexp.XmlExpr
+}
+
+// compiledConversation is a conversation with its request matchers compiled.
+type compiledConversation struct {
+	Conversation
+	matchers  *requestMatchers
+	wsMatcher []*regexp.Regexp // matchers of WebSocket.Messages, by position
+}
+
+// compileConversation compiles the request matchers of c, and the matchers of
+// its websocket messages, if any.
+func compileConversation(c Conversation) (*compiledConversation, error) {
+	matchers, err := compileMatchers(c.Request)
+	if err != nil {
+		return nil, err
+	}
+	compiled := &compiledConversation{Conversation: c, matchers: matchers}
+	if c.WebSocket != nil {
+		compiled.wsMatcher = make([]*regexp.Regexp, len(c.WebSocket.Messages))
+		for i, m := range c.WebSocket.Messages {
+			if compiled.wsMatcher[i], err = regexp.Compile(m.Matcher); err != nil {
+				return nil, fmt.Errorf("websocket.messages[%d].matcher: %v", i, err)
+			}
+		}
+	}
+	return compiled, nil
+}
+
+func compileMatchers(r Request) (*requestMatchers, error) {
+	m := &requestMatchers{}
+	var err error
+	if r.HostMatcher != "" {
+		if m.host, err = regexp.Compile(r.HostMatcher); err != nil {
+			return nil, fmt.Errorf("host-matcher: %v", err)
+		}
+	}
+	if r.MethodMatcher != "" {
+		if m.method, err = regexp.Compile(r.MethodMatcher); err != nil {
+			return nil, fmt.Errorf("method-matcher: %v", err)
+		}
+	}
+	if r.UrlMatcher.PathTemplate != "" {
+		expr, err := compilePathTemplate(r.UrlMatcher.PathTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("url-matcher.path-template: %v", err)
+		}
+		m.path = regexp.MustCompile(expr)
+	} else if r.UrlMatcher.Path != "" {
+		if m.path, err = regexp.Compile(r.UrlMatcher.Path); err != nil {
+			return nil, fmt.Errorf("url-matcher.path: %v", err)
+		}
+	}
+	if r.UrlMatcher.Query != "" {
+		if m.query, err = queryexp.Compile(r.UrlMatcher.Query); err != nil {
+			return nil, fmt.Errorf("url-matcher.query: %v", err)
+		}
+		if m.rawQuery, err = regexp.Compile(r.UrlMatcher.Query); err != nil {
+			return nil, fmt.Errorf("url-matcher.query: %v", err)
+		}
+	}
+	if len(r.HeaderMatchers) > 0 {
+		if m.headers, err = headerexp.Compile(r.HeaderMatchers...); err != nil {
+			return nil, fmt.Errorf("header-matchers: %v", err)
+		}
+	}
+	if r.BodyMatcher != "" {
+		if m.body, err = regexp.Compile(r.BodyMatcher); err != nil {
+			return nil, fmt.Errorf("body-matcher: %v", err)
+		}
+	}
+	if len(r.JsonMatchers) > 0 {
+		if m.json, err = jsonexp.Compile(r.JsonMatchers); err != nil {
+			return nil, fmt.Errorf("json-matchers: %v", err)
+		}
+	}
+	if len(r.XmlMatchers) > 0 {
+		if m.xml, err = xmlexp.Compile(r.XmlMatchers, r.XmlNamespaces); err != nil {
+			return nil, fmt.Errorf("xml-matchers: %v", err)
+		}
+	}
+	return m, nil
+}
+
+// indexKey selects the conversations that may match a method, "" for all, and
+// a path starting with prefix.
+type indexKey struct {
+	method string
+	prefix string
+}
+
+// conversationIndex holds the compiled conversations of a handler, indexed by
+// the methods they match and the literal prefix of the paths they match, so
+// only conversations that may match a request are matched against it.
+type conversationIndex struct {
+	conversations []*compiledConversation // by match-order
+	keys          map[indexKey][]int      // positions in conversations
+	templates     *templateCache
+}
+
+// newConversationIndex compiles conversations and indexes them, conversations
+// that fail to compile are returned as errors, and are left out.
+func newConversationIndex(conversations []Conversation) (*conversationIndex, []error) {
+	var errs []error
+	idx := &conversationIndex{keys: make(map[indexKey][]int), templates: newTemplateCache()}
+	for _, c := range conversations {
+		compiled, err := compileConversation(c)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("conversation '%s': %v", c.Name, err))
+			continue
+		}
+		pos := len(idx.conversations)
+		idx.conversations = append(idx.conversations, compiled)
+		for _, key := range indexKeys(c, compiled.matchers) {
+			idx.keys[key] = append(idx.keys[key], pos)
+		}
+		idx.templates.compileResponses(c)
+	}
+	return idx, errs
+}
+
+// indexKeys returns the keys a conversation is indexed by. Conversations breaking
+// on no-match must be matched against all requests, and are indexed by the empty key.
+func indexKeys(c Conversation, m *requestMatchers) []indexKey {
+	if c.BreakOnNoMatch() {
+		return []indexKey{{}}
+	}
+	prefix := ""
+	if m.path != nil && isAnchored(m.path.String()) {
+		prefix, _ = m.path.LiteralPrefix()
+	}
+	if m.method == nil {
+		return []indexKey{{prefix: prefix}}
+	}
+	var keys []indexKey
+	for _, method := range indexMethods {
+		if m.method.MatchString(method) {
+			keys = append(keys, indexKey{method: method, prefix: prefix})
+		}
+	}
+	return keys
+}
+
+// isAnchored returns true if the regular expression only matches at the start of
+// the text.
+func isAnchored(expr string) bool {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return false
+	}
+	for re.Op == syntax.OpConcat || re.Op == syntax.OpCapture {
+		if len(re.Sub) == 0 {
+			return false
+		}
+		re = re.Sub[0]
+	}
+	return re.Op == syntax.OpBeginText
+}
+
+// candidates returns the conversations that may match a request of method and
+// path, by match-order.
+func (idx *conversationIndex) candidates(method string, path string) []*compiledConversation {
+	if !isIndexMethod(method) {
+		return idx.conversations
+	}
+	var positions []int
+	for _, m := range []string{"", method} {
+		for i := 0; i <= len(path); i++ {
+			positions = append(positions, idx.keys[indexKey{method: m, prefix: path[:i]}]...)
+		}
+	}
+	sort.Ints(positions)
+	candidates := make([]*compiledConversation, len(positions))
+	for i, pos := range positions {
+		candidates[i] = idx.conversations[pos]
+	}
+	return candidates
+}
+
+func isIndexMethod(method string) bool {
+	for _, m := range indexMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// templateCache holds parsed templates by name and text, so templates are only
+// parsed once.
+type templateCache struct {
+	sync.RWMutex
+	templates map[string]*template.Template
+}
+
+func newTemplateCache() *templateCache {
+	return &templateCache{templates: make(map[string]*template.Template)}
+}
+
+// get returns text parsed as a template, see parseTemplate.
+func (tc *templateCache) get(name string, text string) (*template.Template, error) {
+	key := name + "\x00" + text
+	tc.RLock()
+	tmpl, found := tc.templates[key]
+	tc.RUnlock()
+	if found {
+		return tmpl, nil
+	}
+	tmpl, err := parseTemplate(name, text)
+	if err != nil {
+		return nil, err
+	}
+	tc.Lock()
+	tc.templates[key] = tmpl
+	tc.Unlock()
+	return tmpl, nil
+}
+
+// compileResponses parses the header and body templates of the responses of c,
+// templates that fail to parse are reported when served.
+func (tc *templateCache) compileResponses(c Conversation) {
+	for _, r := range append([]Response{c.Response}, c.Responses...) {
+		for _, s := range r.Headers {
+			_, _ = tc.get("header", s)
+		}
+		if r.Body != "" && !r.RawBody {
+			_, _ = tc.get("body", r.Body)
+		}
+	}
+}
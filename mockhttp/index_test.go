@@ -0,0 +1,153 @@
+package mockhttp
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIndexKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Conversation
+		want []indexKey
+	}{
+		{"no matchers", Conversation{}, []indexKey{{}}},
+		{"anchored path", Conversation{Request: Request{UrlMatcher: UrlMatcher{Path: "^/api/v1/"}}}, []indexKey{{prefix: "/api/v1/"}}},
+		{"begin text", Conversation{Request: Request{UrlMatcher: UrlMatcher{Path: `\A/api`}}}, []indexKey{{prefix: "/api"}}},
+		{"unanchored path", Conversation{Request: Request{UrlMatcher: UrlMatcher{Path: "/api/v1/"}}}, []indexKey{{}}},
+		{"multi-line path", Conversation{Request: Request{UrlMatcher: UrlMatcher{Path: "(?m)^/api"}}}, []indexKey{{}}},
+		{"alternate path", Conversation{Request: Request{UrlMatcher: UrlMatcher{Path: "^/api|^/x"}}}, []indexKey{{}}},
+		{"case insensitive path", Conversation{Request: Request{UrlMatcher: UrlMatcher{Path: "(?i)^/API/users"}}}, []indexKey{{prefix: "/"}}},
+		{"case insensitive group", Conversation{Request: Request{UrlMatcher: UrlMatcher{Path: "^/a(?i:B)c"}}}, []indexKey{{prefix: "/a"}}},
+		{"path template", Conversation{Request: Request{UrlMatcher: UrlMatcher{PathTemplate: "/users/{id}"}}}, []indexKey{{prefix: "/users/"}}},
+		{"method", Conversation{Request: Request{MethodMatcher: "^GET$"}}, []indexKey{{method: "GET"}}},
+		{"methods and path", Conversation{Request: Request{MethodMatcher: "GET|POST", UrlMatcher: UrlMatcher{Path: "^/a"}}},
+			[]indexKey{{method: "GET", prefix: "/a"}, {method: "POST", prefix: "/a"}}},
+		{"method regexp", Conversation{Request: Request{MethodMatcher: "^P"}}, []indexKey{{method: "POST"}, {method: "PUT"}, {method: "PATCH"}}},
+		{"lower case method", Conversation{Request: Request{MethodMatcher: "^get$"}}, nil},
+		{"case insensitive method", Conversation{Request: Request{MethodMatcher: "(?i)^get$"}}, []indexKey{{method: "GET"}}},
+		{"other method", Conversation{Request: Request{MethodMatcher: "^PROPFIND$"}}, nil},
+		{"break on no-match", Conversation{BreakOn: NoMatch, Request: Request{MethodMatcher: "^GET$", UrlMatcher: UrlMatcher{Path: "^/a"}}}, []indexKey{{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := compileMatchers(tt.c.Request)
+			if err != nil {
+				t.Fatalf("compileMatchers() %v", err)
+			}
+			if got := indexKeys(tt.c, m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("indexKeys() %v, expected %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// indexTestConversations are matched by TestCandidates and TestSelectConversation.
+var indexTestConversations = []Conversation{
+	{Name: "api", Order: 0, Request: Request{UrlMatcher: UrlMatcher{Path: "^/api/"}}},
+	{Name: "users", Order: 0, Request: Request{MethodMatcher: "^GET$", UrlMatcher: UrlMatcher{Path: "^/api/users"}}},
+	{Name: "user", Order: 0, Request: Request{MethodMatcher: "^GET$", UrlMatcher: UrlMatcher{PathTemplate: "/api/users/{id}", Query: "v=^1$"}}},
+	{Name: "insensitive", Order: 1, Request: Request{UrlMatcher: UrlMatcher{Path: "(?i)^/API/Users$"}}},
+	{Name: "post", Order: 1, Request: Request{MethodMatcher: "^POST$"}},
+	{Name: "lower", Order: 1, Request: Request{MethodMatcher: "^get$"}},
+	{Name: "suffix", Order: 2, Request: Request{UrlMatcher: UrlMatcher{Path: "/users$"}}},
+	{Name: "any", Order: 3},
+}
+
+func TestCandidates(t *testing.T) {
+	idx, errs := newConversationIndex(indexTestConversations)
+	if len(errs) > 0 {
+		t.Fatalf("newConversationIndex() %v", errs)
+	}
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{"GET", "/api/users/1", "api,users,user,insensitive,suffix,any"},
+		{"GET", "/API/USERS", "insensitive,suffix,any"},
+		{"GET", "/other/users", "insensitive,suffix,any"},
+		{"POST", "/api/users", "api,insensitive,post,suffix,any"},
+		{"DELETE", "/", "insensitive,suffix,any"},
+		{"get", "/api/users", "api,users,user,insensitive,post,lower,suffix,any"},
+		{"PROPFIND", "/", "api,users,user,insensitive,post,lower,suffix,any"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			var names []string
+			for _, c := range idx.candidates(tt.method, tt.path) {
+				names = append(names, c.Name)
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("candidates() %s, expected %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSelectConversation checks that selecting among the candidates of the
+// index, picks the same conversation as matching all conversations.
+func TestSelectConversation(t *testing.T) {
+	h := newTestHandler(indexTestConversations...)
+	tests := []struct {
+		method string
+		target string
+		want   string
+	}{
+		{"GET", "/api/users/1?v=1", "user"}, // path and query scores beat path
+		{"GET", "/api/users/1", "users"},    // first in match-order wins ties
+		{"GET", "/api/other", "api"},
+		{"GET", "/API/USERS", "insensitive"},
+		{"POST", "/api/users", "api"},
+		{"POST", "/users", "post"},
+		{"get", "/x", "lower"},
+		{"GET", "/x/users", "suffix"},
+		{"PROPFIND", "/", "any"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			got, found := h.selectConversation(setContextLogger(contextWithWithSessionId(0), h.Log), r, nil)
+			if !found || got.Name != tt.want {
+				t.Errorf("selectConversation() '%s', expected '%s'", got.Name, tt.want)
+			}
+
+			ctx := setContextLogger(contextWithWithSessionId(0), h.Log)
+			var matching []*compiledConversation
+			for _, c := range h.conversationIndex().conversations {
+				if matchConversation(ctx, h.ScenarioStates(), r, &requestBody{}, c).all() {
+					matching = append(matching, c)
+				}
+			}
+			score, _ := getConversationScores(ctx)
+			if all, err := score.tieBreak(matching); err != nil || all.Name != got.Name {
+				t.Errorf("tieBreak() of all conversations '%s', expected '%s'", all.Name, got.Name)
+			}
+		})
+	}
+}
+
+// TestConversationIndex_WithoutLock checks that templates are looked up while
+// the handler is locked, once the conversations are indexed.
+func TestConversationIndex_WithoutLock(t *testing.T) {
+	h := newTestHandler(Conversation{Name: "c", Response: Response{StatusCode: 200, Body: "c"}})
+	h.conversationIndex()
+	h.Lock()
+	defer h.Unlock()
+	done := make(chan error)
+	go func() {
+		_, err := h.template("c", "{{ .request.Path }}")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("template() %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("template() blocked on the handler lock")
+	}
+}
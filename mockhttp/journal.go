@@ -0,0 +1,236 @@
+package mockhttp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/thorsager/mockdev/headerexp"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// DefaultJournalSize is the number of requests kept in the journal, if not
+// configured.
+const DefaultJournalSize = 1000
+
+// MaxJournalBody is the largest request or response body kept in the journal,
+// longer bodies are truncated.
+const MaxJournalBody = 64 * 1024
+
+// JournalEntry is a request received by the ConversationsHandler, the
+// conversation it matched, if any, and the response served. Status is 0 if no
+// response was served, or the connection was taken over by a fault or websocket.
+type JournalEntry struct {
+	Time            time.Time   `yaml:"time"`
+	SessionId       int         `yaml:"session-id"`
+	RemoteAddr      string      `yaml:"remote-addr"`
+	TLS             bool        `yaml:"tls,omitempty"`
+	Host            string      `yaml:"host,omitempty"`
+	Method          string      `yaml:"method"`
+	Path            string      `yaml:"path"`
+	Query           string      `yaml:"query,omitempty"`
+	Headers         http.Header `yaml:"headers,omitempty"`
+	Body            string      `yaml:"body,omitempty"`      // at most MaxJournalBody
+	BodySize        int         `yaml:"body-size,omitempty"` // size of the whole body
+	Matched         bool        `yaml:"matched"`
+	Conversation    string      `yaml:"conversation,omitempty"`
+	Proxied         bool        `yaml:"proxied,omitempty"` // passed through to the upstream
+	Status          int         `yaml:"status,omitempty"`
+	ResponseHeaders http.Header `yaml:"response-headers,omitempty"`
+	ResponseBody    string      `yaml:"response-body,omitempty"` // at most MaxJournalBody
+	ResponseSize    int         `yaml:"response-size,omitempty"` // size of the whole body
+	Duration        Duration    `yaml:"duration,omitempty"`
+	seq             int
+}
+
+// JournalQuery selects entries from the journal, all fields are optional and
+// only entries matching all configured fields are selected. Method, Path and Body
+// are regular expressions, Headers are header-matchers that must all be present.
+type JournalQuery struct {
+	Method       string   `yaml:"method,omitempty"`
+	Path         string   `yaml:"path,omitempty"`
+	Headers      []string `yaml:"headers,omitempty"`
+	Body         string   `yaml:"body,omitempty"`
+	Conversation string   `yaml:"conversation,omitempty"`
+	Unmatched    bool     `yaml:"unmatched,omitempty"`
+}
+
+type journal struct {
+	entries []JournalEntry
+	lastSeq int
+}
+
+func (j *journal) add(e JournalEntry, size int) int {
+	if size < 0 {
+		return 0 // journal disabled
+	}
+	if size == 0 {
+		size = DefaultJournalSize
+	}
+	j.lastSeq++
+	e.seq = j.lastSeq
+	j.entries = append(j.entries, e)
+	if len(j.entries) > size {
+		j.entries = append([]JournalEntry(nil), j.entries[len(j.entries)-size:]...)
+	}
+	return e.seq
+}
+
+// Journal returns all requests in the journal, oldest first.
+func (h *ConversationsHandler) Journal() []JournalEntry {
+	h.Lock()
+	defer h.Unlock()
+	return append([]JournalEntry(nil), h.journal.entries...)
+}
+
+// ClearJournal removes all requests from the journal.
+func (h *ConversationsHandler) ClearJournal() {
+	h.Lock()
+	defer h.Unlock()
+	h.journal.entries = nil
+}
+
+// FindRequests returns all requests in the journal selected by q, oldest first.
+func (h *ConversationsHandler) FindRequests(q JournalQuery) ([]JournalEntry, error) {
+	matches, err := q.compile()
+	if err != nil {
+		return nil, err
+	}
+	var found []JournalEntry
+	for _, e := range h.Journal() {
+		if matches(e) {
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
+
+// record adds e to the journal, and returns the sequence number used to complete
+// it with the response, 0 if the journal is disabled.
+func (h *ConversationsHandler) record(e JournalEntry) int {
+	h.Lock()
+	defer h.Unlock()
+	return h.journal.add(e, h.JournalSize)
+}
+
+// completeRecord adds the response captured by c to the journal entry seq, if it
+// is still in the journal.
+func (h *ConversationsHandler) completeRecord(seq int, c *responseCapture) {
+	if seq == 0 {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	for i := len(h.journal.entries) - 1; i >= 0; i-- {
+		e := &h.journal.entries[i]
+		if e.seq == seq {
+			if !c.hijacked {
+				e.Status = c.status
+				e.ResponseHeaders = c.Header().Clone()
+			}
+			e.ResponseBody = c.body.String()
+			e.ResponseSize = c.size
+			e.Duration = Duration(time.Since(e.Time))
+			return
+		}
+	}
+}
+
+// journalBody returns body as kept in the journal, truncated to MaxJournalBody.
+func journalBody(body []byte) string {
+	if len(body) > MaxJournalBody {
+		body = body[:MaxJournalBody]
+	}
+	return string(body)
+}
+
+// responseCapture is a http.ResponseWriter that keeps the status, and the first
+// MaxJournalBody bytes of the body written, for the journal.
+type responseCapture struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	size     int
+	hijacked bool
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.size += len(b)
+	if room := MaxJournalBody - c.body.Len(); room > 0 {
+		if room > len(b) {
+			room = len(b)
+		}
+		c.body.Write(b[:room])
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *responseCapture) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *responseCapture) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("connection can not be taken over")
+	}
+	c.hijacked = true
+	return hj.Hijack()
+}
+
+func (q JournalQuery) compile() (func(JournalEntry) bool, error) {
+	var method, path, body *regexp.Regexp
+	var headers *headerexp.HeaderExpr
+	var err error
+	if q.Method != "" {
+		if method, err = regexp.Compile(q.Method); err != nil {
+			return nil, err
+		}
+	}
+	if q.Path != "" {
+		if path, err = regexp.Compile(q.Path); err != nil {
+			return nil, err
+		}
+	}
+	if q.Body != "" {
+		if body, err = regexp.Compile(q.Body); err != nil {
+			return nil, err
+		}
+	}
+	if len(q.Headers) > 0 {
+		if headers, err = headerexp.Compile(q.Headers...); err != nil {
+			return nil, err
+		}
+	}
+	return func(e JournalEntry) bool {
+		switch {
+		case method != nil && !method.MatchString(e.Method):
+			return false
+		case path != nil && !path.MatchString(e.Path):
+			return false
+		case body != nil && !body.MatchString(e.Body):
+			return false
+		case headers != nil && !headers.ContainedInHeader(e.Headers):
+			return false
+		case q.Conversation != "" && q.Conversation != e.Conversation:
+			return false
+		case q.Unmatched && e.Matched:
+			return false
+		}
+		return true
+	}, nil
+}
@@ -0,0 +1,129 @@
+package mockhttp
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournal_Add(t *testing.T) {
+	var j journal
+	for i := 1; i <= 5; i++ {
+		if seq := j.add(JournalEntry{Path: string(rune('a' + i - 1))}, 3); seq != i {
+			t.Errorf("add() %d, expected %d", seq, i)
+		}
+	}
+	var paths []string
+	for _, e := range j.entries {
+		paths = append(paths, e.Path)
+	}
+	if want := []string{"c", "d", "e"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("journal %v, expected the newest %v", paths, want)
+	}
+}
+
+func TestJournal_Disabled(t *testing.T) {
+	h := newTestHandler(Conversation{Name: "c", Response: Response{StatusCode: 200, Body: "c"}})
+	h.JournalSize = -1
+	if got := serve(h, "GET", "/"); got != "c" {
+		t.Errorf("GET / = %s, expected c", got)
+	}
+	if entries := h.Journal(); len(entries) != 0 {
+		t.Errorf("journal %+v, expected none when disabled", entries)
+	}
+}
+
+func TestJournal_RequestBody(t *testing.T) {
+	h := newTestHandler(Conversation{Name: "c", Response: Response{StatusCode: 200, Body: "c"}})
+	body := strings.Repeat("x", MaxJournalBody+10)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	entries := h.Journal()
+	if len(entries) != 1 {
+		t.Fatalf("%d journal entries, expected 1", len(entries))
+	}
+	if e := entries[0]; len(e.Body) != MaxJournalBody || e.BodySize != len(body) {
+		t.Errorf("body of %d bytes, size %d, expected %d bytes of %d", len(e.Body), e.BodySize, MaxJournalBody, len(body))
+	}
+	if e := entries[0]; e.Status != 200 || e.ResponseBody != "c" || e.ResponseSize != 1 || e.Conversation != "c" {
+		t.Errorf("entry %+v, expected the response of c", e)
+	}
+}
+
+// TestJournal_Hijacked checks the journal entry of a request served by a fault,
+// that takes over the connection.
+func TestJournal_Hijacked(t *testing.T) {
+	h := newTestHandler(Conversation{Name: "fault", Response: Response{StatusCode: 200, Body: "hello world",
+		Fault: &Fault{Type: FaultCloseAfter, Bytes: 5}}})
+	server := httptest.NewServer(h)
+	defer server.Close()
+	if resp, err := server.Client().Get(server.URL + "/"); err == nil {
+		_, _ = ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entries := h.Journal()
+		if len(entries) == 1 && entries[0].Duration != 0 {
+			if e := entries[0]; e.Status != 0 || e.ResponseHeaders != nil || !e.Matched {
+				t.Errorf("entry %+v, expected matched without status or headers", e)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("journal %+v, expected a completed entry", entries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJournalQuery(t *testing.T) {
+	entries := []JournalEntry{
+		{Method: "GET", Path: "/status", Matched: true, Conversation: "status",
+			Headers: map[string][]string{"Accept": {"application/json"}}},
+		{Method: "POST", Path: "/config", Body: `{"name": "x"}`, Matched: true, Conversation: "config"},
+		{Method: "GET", Path: "/other"},
+	}
+	tests := []struct {
+		name    string
+		q       JournalQuery
+		want    []string // paths
+		wantErr bool
+	}{
+		{"all", JournalQuery{}, []string{"/status", "/config", "/other"}, false},
+		{"method", JournalQuery{Method: "^GET$"}, []string{"/status", "/other"}, false},
+		{"path", JournalQuery{Path: "^/conf"}, []string{"/config"}, false},
+		{"headers", JournalQuery{Headers: []string{"Accept: json"}}, []string{"/status"}, false},
+		{"body", JournalQuery{Body: `"name": "x"`}, []string{"/config"}, false},
+		{"conversation", JournalQuery{Conversation: "status"}, []string{"/status"}, false},
+		{"unmatched", JournalQuery{Unmatched: true}, []string{"/other"}, false},
+		{"all of", JournalQuery{Method: "^GET$", Unmatched: true}, []string{"/other"}, false},
+		{"invalid method", JournalQuery{Method: "("}, nil, true},
+		{"invalid path", JournalQuery{Path: "("}, nil, true},
+		{"invalid body", JournalQuery{Body: "("}, nil, true},
+		{"invalid headers", JournalQuery{Headers: []string{"Accept"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := tt.q.compile()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compile() error %v, expected error %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var got []string
+			for _, e := range entries {
+				if matches(e) {
+					got = append(got, e.Path)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selected %v, expected %v", got, tt.want)
+			}
+		})
+	}
+}
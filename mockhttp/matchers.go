@@ -1,32 +1,99 @@
 package mockhttp
 
 import (
-	"bytes"
 	"context"
-	"github.com/thorsager/mockdev/headerexp"
-	"github.com/thorsager/mockdev/queryexp"
-	"io/ioutil"
+	"fmt"
+	"github.com/thorsager/mockdev/jsonexp"
+	"github.com/thorsager/mockdev/websocket"
+	"github.com/thorsager/mockdev/xmlexp"
 	"net/http"
-	"regexp"
 )
 
-func matchURL(ctx context.Context, r *http.Request, c Conversation) bool {
+// requestBody is the body of the request being matched, decoded as JSON and XML
+// at most once, when needed by a matcher.
+type requestBody struct {
+	raw         []byte
+	jsonDoc     interface{}
+	jsonErr     error
+	jsonDecoded bool
+	xmlDoc      *xmlexp.Node
+	xmlErr      error
+	xmlDecoded  bool
+}
+
+func (b *requestBody) json() (interface{}, error) {
+	if !b.jsonDecoded {
+		b.jsonDoc, b.jsonErr = jsonexp.Decode(b.raw)
+		b.jsonDecoded = true
+	}
+	return b.jsonDoc, b.jsonErr
+}
+
+func (b *requestBody) xml() (*xmlexp.Node, error) {
+	if !b.xmlDecoded {
+		b.xmlDoc, b.xmlErr = xmlexp.Decode(b.raw)
+		b.xmlDecoded = true
+	}
+	return b.xmlDoc, b.xmlErr
+}
+
+// matchResult is the result of each matcher of a conversation, matching a request.
+type matchResult struct {
+	scenario, host, method, url, headers, body, upgrade bool
+}
+
+func (m matchResult) all() bool {
+	return m.scenario && m.host && m.method && m.url && m.headers && m.body && m.upgrade
+}
+
+// failed returns the names of the matchers that did not match.
+func (m matchResult) failed() []string {
+	var failed []string
+	for _, r := range []struct {
+		name  string
+		match bool
+	}{{"scenario", m.scenario}, {"host", m.host}, {"method", m.method}, {"url", m.url}, {"headers", m.headers}, {"body", m.body}, {"upgrade", m.upgrade}} {
+		if !r.match {
+			failed = append(failed, r.name)
+		}
+	}
+	return failed
+}
+
+func (m matchResult) String() string {
+	return fmt.Sprintf("scenarioMatch=%t, hostMatch=%t, methodMatch=%t, urlMatch=%t, headerMatch=%t, bodyMatch=%t, upgradeMatch=%t", m.scenario, m.host, m.method, m.url, m.headers, m.body, m.upgrade)
+}
+
+// matchConversation matches r against all matchers of c, every matcher that
+// matches counts towards the score of c.
+func matchConversation(ctx context.Context, states scenarioStates, r *http.Request, body *requestBody, c *compiledConversation) matchResult {
+	return matchResult{
+		scenario: matchScenario(ctx, states, c),
+		host:     matchHost(ctx, r, c),
+		method:   matchMethod(ctx, r, c),
+		url:      matchURL(ctx, r, c),
+		headers:  matchHeaders(ctx, r, c),
+		body:     matchBody(ctx, body, c),
+		upgrade:  c.WebSocket == nil || websocket.IsUpgrade(r),
+	}
+}
+
+func matchURL(ctx context.Context, r *http.Request, c *compiledConversation) bool {
 	score, _ := getConversationScores(ctx)
 	if c.Request.UrlMatcher == (UrlMatcher{}) {
 		return true // no matcher, (that is a win)
 	}
 
 	pathMatch := true
-	if c.Request.UrlMatcher.Path != "" {
-		urlPath := regexp.MustCompile(c.Request.UrlMatcher.Path)
-		if pathMatch = urlPath.MatchString(r.URL.Path); pathMatch {
+	if c.matchers.path != nil {
+		if pathMatch = c.matchers.path.MatchString(r.URL.Path); pathMatch {
 			score.inc(c.Name)
 		}
 	}
 
 	queryMatch := true
-	if c.Request.UrlMatcher.Query != "" {
-		urlQuery := queryexp.MustCompile(c.Request.UrlMatcher.Query)
+	if c.matchers.query != nil {
+		urlQuery := c.matchers.query
 		if c.Request.UrlMatcher.QueryLooseMatch {
 			queryMatch = urlQuery.ContainedInQuery(r.URL.Query())
 		} else {
@@ -40,48 +107,74 @@ func matchURL(ctx context.Context, r *http.Request, c Conversation) bool {
 	return pathMatch && queryMatch
 }
 
-func matchBody(ctx context.Context, r *http.Request, c Conversation) bool {
+func matchBody(ctx context.Context, body *requestBody, c *compiledConversation) bool {
 	score, _ := getConversationScores(ctx)
-	log, _ := getContextLogger(ctx)
 
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		log.Errorf("%v", err)
-		return false
+	if c.matchers.body != nil {
+		if !c.matchers.body.Match(body.raw) {
+			return false
+		}
+		score.inc(c.Name)
+	}
+	if c.matchers.json != nil {
+		doc, err := body.json()
+		if err != nil || !c.matchers.json.MatchDocument(doc) {
+			return false
+		}
+		score.bump(c.Name, c.matchers.json.MatcherCount())
+	}
+	if c.matchers.xml != nil {
+		doc, err := body.xml()
+		if err != nil || !c.matchers.xml.MatchDocument(doc) {
+			return false
+		}
+		score.bump(c.Name, c.matchers.xml.MatcherCount())
+	}
+	return true // no matchers, or all matched
+}
+
+func matchScenario(ctx context.Context, states scenarioStates, c *compiledConversation) bool {
+	score, _ := getConversationScores(ctx)
+	if c.RequiredState == "" {
+		return true // no required state, that is a win
 	}
-	_ = r.Body.Close() //  must close
-	r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	if states.get(c.Scenario) == c.RequiredState {
+		score.inc(c.Name)
+		return true
+	}
+	return false
+}
 
-	if c.Request.BodyMatcher == "" {
+func matchHost(ctx context.Context, r *http.Request, c *compiledConversation) bool {
+	score, _ := getConversationScores(ctx)
+	if c.matchers.host == nil {
 		return true // no matcher, that is a win
 	}
-	method := regexp.MustCompile(c.Request.BodyMatcher)
-	if method.Match(body) {
+	if c.matchers.host.MatchString(requestHost(r.Host)) {
 		score.inc(c.Name)
 		return true
 	}
 	return false
 }
 
-func matchMethod(ctx context.Context, r *http.Request, c Conversation) bool {
+func matchMethod(ctx context.Context, r *http.Request, c *compiledConversation) bool {
 	score, _ := getConversationScores(ctx)
-	if c.Request.MethodMatcher == "" {
+	if c.matchers.method == nil {
 		return true // no matcher, that is a win
 	}
-	method := regexp.MustCompile(c.Request.MethodMatcher)
-	if method.MatchString(r.Method) {
+	if c.matchers.method.MatchString(r.Method) {
 		score.inc(c.Name)
 		return true
 	}
 	return false
 }
 
-func matchHeaders(ctx context.Context, r *http.Request, c Conversation) bool {
+func matchHeaders(ctx context.Context, r *http.Request, c *compiledConversation) bool {
 	score, _ := getConversationScores(ctx)
-	if len(c.Request.HeaderMatchers) == 0 {
+	if c.matchers.headers == nil {
 		return true // mo matchers, that is a win
 	}
-	headers := headerexp.MustCompile(c.Request.HeaderMatchers...)
+	headers := c.matchers.headers
 	var doesMatch bool
 	if c.Request.GetHeaderMatchType() == Contains {
 		doesMatch = headers.ContainedInHeader(r.Header)
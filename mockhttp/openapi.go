@@ -0,0 +1,61 @@
+package mockhttp
+
+import (
+	"fmt"
+	"github.com/thorsager/mockdev/openapi"
+	"sort"
+	"strings"
+)
+
+// ConversationsFromOpenAPI turns each operation of an OpenAPI 3 document into a
+// conversation, matching the method and path of the operation, and responding
+// with the example, or a body generated from the schema, of its first successful
+// response. Operations with fewer path parameters get a lower match-order, so
+// that '/devices/new' is preferred to '/devices/{id}'.
+func ConversationsFromOpenAPI(data []byte) ([]Conversation, error) {
+	doc, err := openapi.Load(data)
+	if err != nil {
+		return nil, err
+	}
+	operations, err := doc.Operations()
+	if err != nil {
+		return nil, err
+	}
+	conversations := make([]Conversation, 0, len(operations))
+	for _, op := range operations {
+		name := op.Id
+		if name == "" {
+			name = fmt.Sprintf("%s %s", op.Method, op.Path)
+		}
+		c := Conversation{
+			Name:  name,
+			Order: strings.Count(op.Path, "{"),
+			Request: Request{
+				UrlMatcher:    UrlMatcher{Path: openapi.PathRegexp(op.Path, op.PathParams)},
+				MethodMatcher: "^" + op.Method + "$",
+			},
+			Response: Response{
+				StatusCode: op.StatusCode,
+				Body:       string(op.Body),
+				RawBody:    true,
+			},
+		}
+		if op.ContentType != "" {
+			contentType := op.ContentType
+			if strings.Contains(contentType, "*") {
+				contentType = "application/json"
+			}
+			c.Response.Headers = append(c.Response.Headers, "Content-Type: "+contentType)
+		}
+		names := make([]string, 0, len(op.Headers))
+		for k := range op.Headers {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			c.Response.Headers = append(c.Response.Headers, fmt.Sprintf("%s: %s", k, op.Headers[k]))
+		}
+		conversations = append(conversations, c)
+	}
+	return conversations, nil
+}
@@ -0,0 +1,98 @@
+package mockhttp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/thorsager/mockdev/logging"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PassthroughConfiguration proxies requests not matched by any conversation to
+// Upstream, instead of serving the unmatched response.
+type PassthroughConfiguration struct {
+	Upstream           string               `yaml:"upstream"`
+	InsecureSkipVerify bool                 `yaml:"insecure-skip-verify,omitempty"` // do not verify the certificate of a HTTPS upstream
+	Record             *RecordConfiguration `yaml:"record,omitempty"`               // record proxied exchanges, if set
+}
+
+// RecordConfiguration configures the recording of proxied exchanges as
+// conversations, see Recorder.
+type RecordConfiguration struct {
+	File          string   `yaml:"file"`                      // conversations recorded earlier are kept
+	BodyDir       string   `yaml:"body-dir,omitempty"`        // default '<file>_bodies'
+	MaxInlineBody int      `yaml:"max-inline-body,omitempty"` // default DefaultMaxInlineBody
+	RedactHeaders []string `yaml:"redact-headers,omitempty"`  // default DefaultRedactHeaders
+	MatchHeaders  []string `yaml:"match-headers,omitempty"`
+	Deduplicate   bool     `yaml:"deduplicate,omitempty"`
+	Replay        bool     `yaml:"replay,omitempty"` // serve recorded conversations, instead of proxying the requests again
+}
+
+// NewPassthroughProxy returns a reverse proxy forwarding requests to the
+// configured upstream. If recording is configured, exchanges are recorded, and
+// if replayed, every recorded conversation, including those recorded earlier, is
+// passed to replay.
+func NewPassthroughProxy(config PassthroughConfiguration, logger logging.Logger, replay func(Conversation)) (http.Handler, error) {
+	upstream, err := parseUpstream(config.Upstream)
+	if err != nil {
+		return nil, err
+	}
+	var transport http.RoundTripper
+	if config.InsecureSkipVerify {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		transport = t
+	}
+	if config.Record == nil {
+		return newProxy(upstream, transport, nil, logger, nil), nil
+	}
+
+	record := config.Record
+	recorder := &Recorder{
+		Log:           logger,
+		File:          record.File,
+		BodyDir:       record.BodyDir,
+		MaxInlineBody: record.MaxInlineBody,
+		RedactHeaders: record.RedactHeaders,
+		MatchHeaders:  record.MatchHeaders,
+		Deduplicate:   record.Deduplicate,
+	}
+	if recorder.BodyDir == "" {
+		recorder.BodyDir = strings.TrimSuffix(record.File, filepath.Ext(record.File)) + "_bodies"
+	}
+	if recorder.RedactHeaders == nil {
+		recorder.RedactHeaders = DefaultRedactHeaders
+	}
+	if err := os.MkdirAll(filepath.Dir(record.File), 0770); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(record.File); err == nil {
+		recorded, err := DecodeConversationFile(record.File)
+		if err != nil {
+			return nil, err
+		}
+		recorder.SetConversations(recorded)
+	}
+	if !record.Replay {
+		replay = nil
+	} else if replay != nil {
+		for _, c := range recorder.Conversations() {
+			replay(c)
+		}
+	}
+	return newProxy(upstream, transport, recorder, logger, replay), nil
+}
+
+func parseUpstream(upstream string) (*url.URL, error) {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid upstream '%s', must be a http or https url", upstream)
+	}
+	return u, nil
+}
@@ -0,0 +1,119 @@
+package mockhttp
+
+import (
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// startUpstream starts an upstream answering every request with its path, and
+// returns its url and the number of requests served.
+func startUpstream(t *testing.T) (string, *int32) {
+	t.Helper()
+	var requests int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("upstream " + r.URL.Path))
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream.URL, &requests
+}
+
+// newPassthroughHandler returns a handler serving conversations, and proxying
+// other requests as configured, replaying to the handler.
+func newPassthroughHandler(t *testing.T, config PassthroughConfiguration, conversations ...Conversation) *ConversationsHandler {
+	t.Helper()
+	h := newTestHandler(conversations...)
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	proxy, err := NewPassthroughProxy(config, logger, func(c Conversation) { h.PutConversation(c) })
+	if err != nil {
+		t.Fatalf("NewPassthroughProxy() %v", err)
+	}
+	h.Passthrough = proxy
+	return h
+}
+
+func TestPassthrough(t *testing.T) {
+	upstream, requests := startUpstream(t)
+	h := newPassthroughHandler(t, PassthroughConfiguration{Upstream: upstream},
+		Conversation{Name: "override", Request: Request{UrlMatcher: UrlMatcher{Path: "^/override$"}},
+			Response: Response{StatusCode: 200, Body: "override"}})
+
+	if got := serve(h, "GET", "/override"); got != "override" {
+		t.Errorf("GET /override = %s, expected the conversation", got)
+	}
+	if got := serve(h, "GET", "/other"); got != "upstream /other" {
+		t.Errorf("GET /other = %s, expected it proxied", got)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("upstream served %d requests, expected 1", got)
+	}
+	if entries := h.Journal(); len(entries) != 2 || !entries[1].Proxied {
+		t.Errorf("journal %+v, expected the second request proxied", entries)
+	}
+}
+
+func TestPassthrough_Record(t *testing.T) {
+	upstream, requests := startUpstream(t)
+	file := filepath.Join(t.TempDir(), "recorded", "device.yaml")
+	config := PassthroughConfiguration{Upstream: upstream, Record: &RecordConfiguration{File: file, Deduplicate: true}}
+	h := newPassthroughHandler(t, config)
+
+	for i := 0; i < 2; i++ {
+		if got := serve(h, "GET", "/status?verbose=1"); got != "upstream /status" {
+			t.Errorf("GET /status = %s, expected it proxied", got)
+		}
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("upstream served %d requests, expected 2 without replay", got)
+	}
+	recorded, err := DecodeConversationFile(file)
+	if err != nil {
+		t.Fatalf("DecodeConversationFile() %v", err)
+	}
+	if len(recorded) != 1 {
+		t.Fatalf("%d conversations recorded, expected 1 with deduplicate", len(recorded))
+	}
+	if _, found := h.GetConversation(recorded[0].Name); found {
+		t.Errorf("recorded conversation served, expected it only served with replay")
+	}
+
+	// a restarted passthrough proxy recording to the same file
+	h = newPassthroughHandler(t, config)
+	serve(h, "GET", "/status?verbose=1")
+	serve(h, "GET", "/status?verbose=2")
+	if recorded, _ = DecodeConversationFile(file); len(recorded) != 2 {
+		t.Errorf("%d conversations recorded after restart, expected 2", len(recorded))
+	}
+}
+
+func TestPassthrough_Replay(t *testing.T) {
+	upstream, requests := startUpstream(t)
+	file := filepath.Join(t.TempDir(), "device.yaml")
+	config := PassthroughConfiguration{Upstream: upstream, Record: &RecordConfiguration{File: file, Replay: true}}
+	h := newPassthroughHandler(t, config)
+
+	for i := 0; i < 2; i++ {
+		if got := serve(h, "GET", "/status"); got != "upstream /status" {
+			t.Errorf("GET /status = %s, expected the upstream response", got)
+		}
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("upstream served %d requests, expected 1 with replay", got)
+	}
+
+	// a restarted passthrough proxy replays the conversations recorded earlier
+	h = newPassthroughHandler(t, config)
+	if got := serve(h, "GET", "/status"); got != "upstream /status" {
+		t.Errorf("GET /status = %s after restart, expected the recorded response", got)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("upstream served %d requests, expected none after restart", got-1)
+	}
+}
@@ -0,0 +1,76 @@
+package mockhttp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var pathParamName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// compilePathTemplate converts a path template, such as '/users/{id:[0-9]+}/ports/{port}',
+// to an anchored regular expression with a named group for each parameter. A
+// parameter without a pattern matches a single path segment.
+func compilePathTemplate(tmpl string) (string, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	names := make(map[string]bool)
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			break
+		}
+		if strings.IndexByte(tmpl[:start], '}') >= 0 {
+			return "", fmt.Errorf("unexpected '}'")
+		}
+		end, err := closingBrace(tmpl, start)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(regexp.QuoteMeta(tmpl[:start]))
+
+		param := tmpl[start+1 : end]
+		name, pattern := param, "[^/]+"
+		if i := strings.IndexByte(param, ':'); i >= 0 {
+			name, pattern = param[:i], param[i+1:]
+		}
+		if !pathParamName.MatchString(name) {
+			return "", fmt.Errorf("invalid parameter name '%s'", name)
+		}
+		if names[name] {
+			return "", fmt.Errorf("duplicate parameter '%s'", name)
+		}
+		names[name] = true
+		if pattern == "" {
+			return "", fmt.Errorf("empty pattern of parameter '%s'", name)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return "", fmt.Errorf("parameter '%s': %v", name, err)
+		}
+		fmt.Fprintf(&b, "(?P<%s>%s)", name, pattern)
+		tmpl = tmpl[end+1:]
+	}
+	if strings.IndexByte(tmpl, '}') >= 0 {
+		return "", fmt.Errorf("unexpected '}'")
+	}
+	b.WriteString(regexp.QuoteMeta(tmpl))
+	b.WriteString("$")
+	return b.String(), nil
+}
+
+// closingBrace returns the index of the brace closing the one at start, braces
+// of the pattern, ex. '{id:[0-9]{3}}', must be balanced.
+func closingBrace(s string, start int) (int, error) {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("missing '}' of parameter at %d", start)
+}
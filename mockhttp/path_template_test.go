@@ -0,0 +1,50 @@
+package mockhttp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompilePathTemplate(t *testing.T) {
+	tests := []struct {
+		tmpl    string
+		want    string
+		wantErr string
+	}{
+		{"/users", `^/users$`, ""},
+		{"/users/{id}", `^/users/(?P<id>[^/]+)$`, ""},
+		{"/users/{id:[0-9]+}/ports/{port}", `^/users/(?P<id>[0-9]+)/ports/(?P<port>[^/]+)$`, ""},
+		{"/users/{id:[0-9]{3}}", `^/users/(?P<id>[0-9]{3})$`, ""},
+		{"/a.b/{_id}.json", `^/a\.b/(?P<_id>[^/]+)\.json$`, ""},
+		{"/users/{id}/{id}", "", "duplicate parameter 'id'"},
+		{"/users/{}", "", "invalid parameter name ''"},
+		{"/users/{:[0-9]+}", "", "invalid parameter name ''"},
+		{"/users/{1d}", "", "invalid parameter name '1d'"},
+		{"/users/{id:}", "", "empty pattern of parameter 'id'"},
+		{"/users/{id:(}", "", "parameter 'id':"},
+		{"/users/{id", "", "missing '}' of parameter at 7"},
+		{"/users/{id:[0-9]{3}", "", "missing '}' of parameter at 7"},
+		{"/users/{{id}}", "", "invalid parameter name '{id}'"},
+		{"/users}", "", "unexpected '}'"},
+		{"/a}/{b}", "", "unexpected '}'"},
+		{"/{a}}/{b}", "", "unexpected '}'"},
+		{"/{a}/b}", "", "unexpected '}'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			got, err := compilePathTemplate(tt.tmpl)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("compilePathTemplate() error %v, expected '%s'", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("compilePathTemplate() %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("compilePathTemplate() %s, expected %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package mockhttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/thorsager/mockdev/logging"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultMaxInlineBody is the largest response body, in bytes, that is recorded
+// inline in a conversation, if not configured.
+const DefaultMaxInlineBody = 4096
+
+// RedactedValue replaces the value of redacted headers.
+const RedactedValue = "REDACTED"
+
+// unrecordedHeaders are not recorded, as they describe the connection to the
+// upstream, or the time of the exchange, and not the response.
+var unrecordedHeaders = []string{
+	"Connection", "Content-Length", "Date", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+	"Size", "X-Powered-By", // added by mockdev when serving
+}
+
+// DefaultRedactHeaders are the headers redacted, if not configured.
+var DefaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+var nonNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Recorder turns HTTP exchanges into conversations, that will match the
+// recorded request when replayed.
+type Recorder struct {
+	sync.Mutex
+	Log logging.Logger
+	// File, if set, is rewritten with all recorded conversations every time an
+	// exchange is recorded.
+	File string
+	// BodyDir is where response bodies larger than MaxInlineBody, or not valid
+	// UTF-8, are written as body-files, proxied bodies larger than MaxInlineBody
+	// are written there as they are read. If not set, all bodies are kept in the
+	// conversations.
+	BodyDir       string
+	MaxInlineBody int      // default DefaultMaxInlineBody
+	RedactHeaders []string // request and response headers, that are recorded as RedactedValue
+	MatchHeaders  []string // request headers, that are recorded as header-matchers
+	Deduplicate   bool     // only record the first of requests recorded as the same request
+	conversations []Conversation
+	seen          map[string]bool
+}
+
+// SetConversations sets the conversations recorded earlier, that new
+// recordings are added to, and duplicates are checked against.
+func (r *Recorder) SetConversations(conversations []Conversation) {
+	r.Lock()
+	defer r.Unlock()
+	r.conversations = append([]Conversation(nil), conversations...)
+	r.seen = make(map[string]bool, len(conversations))
+	for _, c := range conversations {
+		r.seen[requestKey(c.Request)] = true
+	}
+}
+
+// Conversations returns the conversations recorded so far.
+func (r *Recorder) Conversations() []Conversation {
+	r.Lock()
+	defer r.Unlock()
+	return append([]Conversation(nil), r.conversations...)
+}
+
+// Record adds the exchange of req and resp as a conversation, the bodies are
+// passed separately as they will have been consumed. The returned bool is false
+// if the request was a duplicate, and not recorded.
+func (r *Recorder) Record(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte) (Conversation, bool, error) {
+	return r.record(req, reqBody, resp, respBody, "")
+}
+
+// record records the exchange as Record does, if spilled is set the response
+// body was written to that file in BodyDir, instead of being passed as respBody,
+// and the file is either made the body-file, or removed.
+func (r *Recorder) record(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, spilled string) (Conversation, bool, error) {
+	r.Lock()
+	defer r.Unlock()
+	if spilled != "" {
+		defer func() { _ = os.Remove(spilled) }() // no-op once renamed
+	}
+
+	conversation := Conversation{
+		Name: r.conversationName(req),
+		Request: Request{
+			UrlMatcher: UrlMatcher{
+				Path:  "^" + regexp.QuoteMeta(req.URL.Path) + "$",
+				Query: queryMatcher(req.URL.Query()),
+			},
+			MethodMatcher: "^" + regexp.QuoteMeta(req.Method) + "$",
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Headers:    r.responseHeaders(resp.Header),
+			RawBody:    true, // recorded bodies are not templates
+		},
+	}
+
+	for _, name := range r.MatchHeaders {
+		name = http.CanonicalHeaderKey(name)
+		if v := req.Header.Get(name); v != "" {
+			if r.isRedacted(name) {
+				conversation.Request.HeaderMatchers = append(conversation.Request.HeaderMatchers, name+": .+")
+			} else {
+				conversation.Request.HeaderMatchers = append(conversation.Request.HeaderMatchers, name+": ^"+regexp.QuoteMeta(v)+"$")
+			}
+		}
+	}
+	if len(conversation.Request.HeaderMatchers) > 0 {
+		conversation.Request.HeaderMatchType = Contains
+	}
+
+	if len(reqBody) > 0 {
+		if len(reqBody) <= r.maxInlineBody() && utf8.Valid(reqBody) {
+			conversation.Request.BodyMatcher = "^" + regexp.QuoteMeta(string(reqBody)) + "$"
+		} else if r.Log != nil {
+			r.Log.Warnf("request body of %s %s not recorded as body-matcher (%d bytes)", req.Method, req.URL.Path, len(reqBody))
+		}
+	}
+
+	key := requestKey(conversation.Request)
+	if r.Deduplicate && r.seen[key] {
+		return Conversation{}, false, nil
+	}
+
+	if spilled == "" && (r.BodyDir == "" || (len(respBody) <= r.maxInlineBody() && utf8.Valid(respBody))) {
+		conversation.Response.Body = string(respBody)
+	} else {
+		filename := filepath.Join(r.BodyDir, conversation.Name+".body")
+		if err := os.MkdirAll(r.BodyDir, 0770); err != nil {
+			return conversation, false, err
+		}
+		if spilled != "" {
+			if err := os.Rename(spilled, filename); err != nil {
+				return conversation, false, err
+			}
+		} else if err := ioutil.WriteFile(filename, respBody, 0644); err != nil {
+			return conversation, false, err
+		}
+		conversation.Response.BodyFile = filename
+	}
+
+	if r.seen == nil {
+		r.seen = make(map[string]bool)
+	}
+	r.seen[key] = true
+	r.conversations = append(r.conversations, conversation)
+
+	if r.File != "" {
+		if err := WriteConversationFile(r.File, r.conversations); err != nil {
+			return conversation, true, err
+		}
+	}
+	return conversation, true, nil
+}
+
+func (r *Recorder) maxInlineBody() int {
+	if r.MaxInlineBody == 0 {
+		return DefaultMaxInlineBody
+	}
+	return r.MaxInlineBody
+}
+
+func (r *Recorder) isRedacted(name string) bool {
+	for _, h := range r.RedactHeaders {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestKey identifies a recorded request by its matchers, requests recorded
+// with the same matchers cannot be told apart when replayed.
+func requestKey(req Request) string {
+	hash := sha256.New()
+	_, _ = fmt.Fprintf(hash, "%s\n%s\n%s\n%s\n", req.MethodMatcher, req.UrlMatcher.Path, req.UrlMatcher.Query, req.BodyMatcher)
+	for _, h := range req.HeaderMatchers {
+		_, _ = fmt.Fprintf(hash, "%s\n", h)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+func (r *Recorder) conversationName(req *http.Request) string {
+	slug := strings.Trim(nonNameChars.ReplaceAllString(strings.ToLower(req.URL.Path), "-"), "-")
+	if slug == "" {
+		slug = "root"
+	}
+	return fmt.Sprintf("%04d-%s-%s", len(r.conversations)+1, strings.ToLower(req.Method), slug)
+}
+
+func (r *Recorder) responseHeaders(header http.Header) []string {
+	var headers []string
+	for name, values := range header {
+		if isUnrecordedHeader(name) {
+			continue
+		}
+		for _, v := range values {
+			if r.isRedacted(name) {
+				v = RedactedValue
+			}
+			headers = append(headers, fmt.Sprintf("%s: %s", name, v))
+		}
+	}
+	sort.Strings(headers)
+	return headers
+}
+
+func isUnrecordedHeader(name string) bool {
+	for _, h := range unrecordedHeaders {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryMatcher returns a query-matcher, matching exactly the first value of
+// all parameters in values.
+func queryMatcher(values url.Values) string {
+	var params []string
+	for k, v := range values {
+		params = append(params, k+"="+queryValueEscaper.Replace("^"+regexp.QuoteMeta(v[0])+"$"))
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// queryValueEscaper escapes characters, that have meaning in a query-matcher.
+var queryValueEscaper = strings.NewReplacer("&", `\x26`, "=", `\x3d`)
+
+// WriteConversationFile writes conversations to filename, any body-file is
+// made relative to the directory of filename, when possible.
+func WriteConversationFile(filename string, conversations []Conversation) error {
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return err
+	}
+	out := make([]Conversation, len(conversations))
+	for i, c := range conversations {
+		if c.Response.BodyFile != "" {
+			if abs, err := filepath.Abs(c.Response.BodyFile); err == nil {
+				if rel, err := filepath.Rel(dir, abs); err == nil {
+					c.Response.BodyFile = rel
+				}
+			}
+		}
+		out[i] = c
+	}
+	data, err := yaml.Marshal(out)
+	if err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// NewRecordingProxy returns a reverse proxy forwarding all requests to
+// upstream, every exchange is recorded using the recorder.
+func NewRecordingProxy(upstream *url.URL, recorder *Recorder, logger logging.Logger) http.Handler {
+	return newProxy(upstream, nil, recorder, logger, nil)
+}
+
+// newProxy returns a reverse proxy forwarding all requests to upstream, using
+// transport, or the default transport if nil. If recorder is set every exchange
+// is recorded, and passed to recorded, if set.
+func newProxy(upstream *url.URL, transport http.RoundTripper, recorder *Recorder, logger logging.Logger, recorded func(Conversation)) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	proxy.Transport = transport
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = upstream.Host
+	}
+	if recorder == nil {
+		return proxy
+	}
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		reqBody := getRequestBody(resp.Request.Context())
+		resp.Body = &recordingBody{ReadCloser: resp.Body, recorder: recorder, done: func(body []byte, spilled string, err error) {
+			if err != nil {
+				logger.Errorf("while recording %s %s: %v", resp.Request.Method, resp.Request.URL.Path, err)
+				return
+			}
+			c, isNew, err := recorder.record(resp.Request, reqBody, resp, body, spilled)
+			if err != nil {
+				logger.Errorf("while recording %s %s: %v", resp.Request.Method, resp.Request.URL.Path, err)
+			} else if isNew {
+				logger.Infof("recorded '%s' (%s %s -> %d)", c.Name, resp.Request.Method, resp.Request.URL.Path, resp.StatusCode)
+				if recorded != nil {
+					recorded(c)
+				}
+			} else {
+				logger.Debugf("duplicate %s %s, not recorded", resp.Request.Method, resp.Request.URL.Path)
+			}
+		}}
+		return nil
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "while reading request body: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		_ = r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+		proxy.ServeHTTP(w, r.WithContext(contextWithRequestBody(r.Context(), body)))
+	})
+}
+
+// recordingBody is a response body, that keeps what is read from it, and passes
+// it to done when the whole body has been read. Bodies longer than the
+// MaxInlineBody of the recorder are spilled to a file in its BodyDir, if set,
+// instead of being kept in memory. If the body is closed before it has been
+// read, or keeping it fails, done is passed the error.
+type recordingBody struct {
+	io.ReadCloser
+	recorder *Recorder
+	done     func(body []byte, spilled string, err error)
+	body     bytes.Buffer
+	spill    *os.File
+	err      error
+	finished bool
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.err == nil {
+		b.err = b.keep(p[:n])
+	}
+	if err == io.EOF {
+		b.finish(nil)
+	} else if err != nil {
+		b.finish(err)
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish(fmt.Errorf("body not read to the end"))
+	return err
+}
+
+func (b *recordingBody) keep(p []byte) error {
+	if b.spill == nil && b.recorder.BodyDir != "" && b.body.Len()+len(p) > b.recorder.maxInlineBody() {
+		if err := os.MkdirAll(b.recorder.BodyDir, 0770); err != nil {
+			return err
+		}
+		spill, err := ioutil.TempFile(b.recorder.BodyDir, ".recording-*")
+		if err != nil {
+			return err
+		}
+		b.spill = spill
+		if _, err := b.body.WriteTo(spill); err != nil {
+			return err
+		}
+	}
+	if b.spill != nil {
+		_, err := b.spill.Write(p)
+		return err
+	}
+	b.body.Write(p)
+	return nil
+}
+
+// finish passes the body to done, or err if the body was not read to the end,
+// only the first call has any effect.
+func (b *recordingBody) finish(err error) {
+	if b.finished {
+		return
+	}
+	b.finished = true
+	if err == nil {
+		err = b.err
+	}
+	var spilled string
+	if b.spill != nil {
+		spilled = b.spill.Name()
+		if cerr := b.spill.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(spilled)
+		}
+	}
+	b.done(b.body.Bytes(), spilled, err)
+}
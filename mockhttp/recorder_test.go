@@ -0,0 +1,121 @@
+package mockhttp
+
+import (
+	"bytes"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordingProxy(t *testing.T) {
+	firmware := bytes.Repeat([]byte{0, 1, 2, 255}, 1024)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/users":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Set-Cookie", "session=secret")
+			_, _ = w.Write([]byte(`{"id": 1}`))
+		case "/firmware":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			for i := 0; i < len(firmware); i += 1000 { // streamed in several writes
+				end := i + 1000
+				if end > len(firmware) {
+					end = len(firmware)
+				}
+				_, _ = w.Write(firmware[i:end])
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+
+	dir := t.TempDir()
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	recorder := &Recorder{
+		Log:           logger,
+		File:          filepath.Join(dir, "recorded.yaml"),
+		BodyDir:       filepath.Join(dir, "bodies"),
+		MaxInlineBody: 64,
+		RedactHeaders: DefaultRedactHeaders,
+		MatchHeaders:  []string{"Accept"},
+		Deduplicate:   true,
+	}
+	proxy := NewRecordingProxy(u, recorder, logger)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", target, nil)
+		r.Header.Set("Accept", "application/json")
+		proxy.ServeHTTP(w, r)
+		return w
+	}
+	if w := get("/api/users?id=1"); w.Body.String() != `{"id": 1}` {
+		t.Errorf("GET /api/users = %s, expected the upstream response", w.Body.String())
+	}
+	get("/api/users?id=1")
+	if w := get("/firmware"); !bytes.Equal(w.Body.Bytes(), firmware) {
+		t.Errorf("GET /firmware served %d bytes, expected %d", w.Body.Len(), len(firmware))
+	}
+
+	recorded, err := DecodeConversationFile(recorder.File)
+	if err != nil {
+		t.Fatalf("DecodeConversationFile() %v", err)
+	}
+	if len(recorded) != 2 {
+		t.Fatalf("%d conversations recorded, expected 2 with deduplicate", len(recorded))
+	}
+	users := recorded[0]
+	want := Request{
+		UrlMatcher:      UrlMatcher{Path: "^/api/users$", Query: "id=^1$"},
+		MethodMatcher:   "^GET$",
+		HeaderMatchType: Contains,
+		HeaderMatchers:  []string{`Accept: ^application/json$`},
+	}
+	if users.Name != "0001-get-api-users" || !reflect.DeepEqual(users.Request, want) {
+		t.Errorf("recorded %s %+v, expected 0001-get-api-users %+v", users.Name, users.Request, want)
+	}
+	if users.Response.Body != `{"id": 1}` || !users.Response.RawBody {
+		t.Errorf("recorded body %q, raw %t, expected the raw upstream body", users.Response.Body, users.Response.RawBody)
+	}
+	if headers := strings.Join(users.Response.Headers, "\n"); !strings.Contains(headers, "Set-Cookie: "+RedactedValue) ||
+		!strings.Contains(headers, "Content-Type: application/json") {
+		t.Errorf("recorded headers %v, expected Content-Type, and Set-Cookie redacted", users.Response.Headers)
+	}
+
+	firmwareFile := recorded[1].Response.BodyFile
+	if data, err := ioutil.ReadFile(firmwareFile); err != nil || !bytes.Equal(data, firmware) {
+		t.Errorf("body-file '%s' of %d bytes (%v), expected the %d bytes of the upstream body", firmwareFile, len(data), err, len(firmware))
+	}
+	if entries, _ := ioutil.ReadDir(recorder.BodyDir); len(entries) != 1 {
+		t.Errorf("%d files in body-dir, expected only the body-file", len(entries))
+	}
+
+	// the recorded conversations serve the recorded responses
+	h := newTestHandler(recorded...)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/firmware", nil)
+	r.Header.Set("Accept", "application/json")
+	h.ServeHTTP(w, r)
+	if !bytes.Equal(w.Body.Bytes(), firmware) {
+		t.Errorf("replayed GET /firmware served %d bytes, expected %d", w.Body.Len(), len(firmware))
+	}
+}
+
+func TestRecordingBody_NotRead(t *testing.T) {
+	var gotErr error
+	b := &recordingBody{ReadCloser: ioutil.NopCloser(strings.NewReader("body")), recorder: &Recorder{},
+		done: func(body []byte, spilled string, err error) { gotErr = err }}
+	buf := make([]byte, 2)
+	_, _ = b.Read(buf)
+	_ = b.Close()
+	if gotErr == nil {
+		t.Errorf("done() without error, expected the body not read to the end")
+	}
+}
@@ -0,0 +1,68 @@
+package mockhttp
+
+// ScenarioStarted is the state all scenarios are in, until a conversation moves
+// them to a new state.
+const ScenarioStarted = "started"
+
+type scenarioStates map[string]string
+
+func (s scenarioStates) get(scenario string) string {
+	if state, found := s[scenario]; found {
+		return state
+	}
+	return ScenarioStarted
+}
+
+func (s scenarioStates) clone() scenarioStates {
+	c := make(scenarioStates, len(s))
+	for k, v := range s {
+		c[k] = v
+	}
+	return c
+}
+
+// ScenarioStates returns a copy of the current state of all scenarios that have
+// left ScenarioStarted.
+func (h *ConversationsHandler) ScenarioStates() map[string]string {
+	h.Lock()
+	defer h.Unlock()
+	return h.scenarios.clone()
+}
+
+// SetScenarioState will force scenario into state.
+func (h *ConversationsHandler) SetScenarioState(scenario, state string) {
+	h.Lock()
+	defer h.Unlock()
+	if h.scenarios == nil {
+		h.scenarios = make(scenarioStates)
+	}
+	h.scenarios[scenario] = state
+}
+
+// ResetScenarios will move all scenarios back to ScenarioStarted.
+func (h *ConversationsHandler) ResetScenarios() {
+	h.Lock()
+	defer h.Unlock()
+	h.scenarios = nil
+}
+
+// transitionScenario moves the scenario of c to its new state, if c still
+// matches the state of its scenario. The check and the transition are done
+// under the same lock, so only one request can move a scenario out of a state.
+// It returns false if the scenario has left the required state of c.
+func (h *ConversationsHandler) transitionScenario(c Conversation) bool {
+	h.Lock()
+	defer h.Unlock()
+	if c.RequiredState != "" && h.scenarios.get(c.Scenario) != c.RequiredState {
+		return false
+	}
+	if c.NewState == "" {
+		return true
+	}
+	h.Log.Debugf("Scenario '%s' moving to state '%s' (%s)", c.Scenario, c.NewState, c.Name)
+	if h.scenarios == nil {
+		h.scenarios = make(scenarioStates)
+	}
+	h.scenarios[c.Scenario] = c.NewState
+	return true
+}
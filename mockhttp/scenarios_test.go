@@ -0,0 +1,110 @@
+package mockhttp
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scenarioTestConversations apply a configuration, and report its status
+// before and after it is applied.
+var scenarioTestConversations = []Conversation{
+	{Name: "apply", Scenario: "config", NewState: "applied",
+		Request:  Request{MethodMatcher: "^POST$"},
+		Response: Response{StatusCode: 200, Body: "apply"}},
+	{Name: "status applied", Scenario: "config", RequiredState: "applied",
+		Request:  Request{MethodMatcher: "^GET$"},
+		Response: Response{StatusCode: 200, Body: "applied"}},
+	{Name: "status", Request: Request{MethodMatcher: "^GET$"},
+		Response: Response{StatusCode: 200, Body: "pending"}},
+}
+
+// serve serves a request to h, and returns the response body.
+func serve(h *ConversationsHandler, method string, target string) string {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w.Body.String()
+}
+
+func TestScenario(t *testing.T) {
+	h := newTestHandler(scenarioTestConversations...)
+	steps := []struct {
+		method string
+		want   string
+		states map[string]string
+	}{
+		{"GET", "pending", map[string]string{}},
+		{"POST", "apply", map[string]string{"config": "applied"}},
+		{"GET", "applied", map[string]string{"config": "applied"}},
+		{"GET", "applied", map[string]string{"config": "applied"}},
+	}
+	for i, s := range steps {
+		if got := serve(h, s.method, "/config"); got != s.want {
+			t.Errorf("[%d] %s /config = %s, expected %s", i, s.method, got, s.want)
+		}
+		if got := h.ScenarioStates(); !reflect.DeepEqual(got, s.states) {
+			t.Errorf("[%d] ScenarioStates() %v, expected %v", i, got, s.states)
+		}
+	}
+
+	h.ResetScenarios()
+	if got := serve(h, "GET", "/config"); got != "pending" {
+		t.Errorf("GET /config after reset = %s, expected pending", got)
+	}
+	h.SetScenarioState("config", "applied")
+	if got := serve(h, "GET", "/config"); got != "applied" {
+		t.Errorf("GET /config after SetScenarioState() = %s, expected applied", got)
+	}
+}
+
+func TestTransitionScenario(t *testing.T) {
+	h := newTestHandler()
+	take := Conversation{Name: "take", Scenario: "s", RequiredState: ScenarioStarted, NewState: "taken"}
+	if !h.transitionScenario(take) {
+		t.Fatalf("transitionScenario() false, expected true")
+	}
+	if h.transitionScenario(take) {
+		t.Errorf("transitionScenario() true after scenario left %s, expected false", ScenarioStarted)
+	}
+	if got := h.ScenarioStates()["s"]; got != "taken" {
+		t.Errorf("state %s, expected taken", got)
+	}
+	if !h.transitionScenario(Conversation{Name: "stateless"}) {
+		t.Errorf("transitionScenario() of stateless conversation false, expected true")
+	}
+}
+
+// TestScenarioConcurrent checks that only one of many concurrent requests is
+// served by a conversation moving its scenario out of the required state, also
+// while the first request is still being served.
+func TestScenarioConcurrent(t *testing.T) {
+	delay := ResponseDelay{Distribution: Fixed, Mean: Duration(50 * time.Millisecond)}
+	h := newTestHandler(
+		Conversation{Name: "first", Scenario: "s", RequiredState: ScenarioStarted, NewState: "taken",
+			Response: Response{StatusCode: 200, Body: "first", Delay: delay}},
+		Conversation{Name: "other", Order: 1, Response: Response{StatusCode: 200, Body: "other"}},
+	)
+	const requests = 50
+	bodies := make(chan string, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bodies <- serve(h, "GET", "/")
+		}()
+	}
+	wg.Wait()
+	close(bodies)
+	first := 0
+	for body := range bodies {
+		if body == "first" {
+			first++
+		}
+	}
+	if first != 1 {
+		t.Errorf("served first %d times, expected 1", first)
+	}
+}
@@ -0,0 +1,135 @@
+package mockhttp
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+const Sequence = "sequence"
+const Cycle = "cycle"
+const Random = "random"
+
+// ResponseSequences returns the number of times each conversation with multiple
+// responses has been served, since it was last reset.
+func (h *ConversationsHandler) ResponseSequences() map[string]int {
+	h.Lock()
+	defer h.Unlock()
+	c := make(map[string]int, len(h.sequences))
+	for k, v := range h.sequences {
+		c[k] = v
+	}
+	return c
+}
+
+// ResetResponseSequences will make all conversations with multiple responses
+// start over from their first response. If names are passed only those
+// conversations are reset.
+func (h *ConversationsHandler) ResetResponseSequences(names ...string) {
+	h.Lock()
+	defer h.Unlock()
+	if len(names) == 0 {
+		h.sequences = nil
+		return
+	}
+	for _, n := range names {
+		delete(h.sequences, n)
+	}
+}
+
+// nextResponse returns the response to serve for the conversation, for
+// conversations with multiple responses this is decided by its response-mode,
+// and the number of times it has been served.
+func (h *ConversationsHandler) nextResponse(c Conversation) Response {
+	if len(c.Responses) == 0 {
+		return c.Response
+	}
+	if c.GetResponseMode() == Random {
+		return h.weightedResponse(c.Responses)
+	}
+	h.Lock()
+	defer h.Unlock()
+	if h.sequences == nil {
+		h.sequences = make(map[string]int)
+	}
+	n := h.sequences[c.Name]
+	h.sequences[c.Name] = n + 1
+	if c.GetResponseMode() == Cycle {
+		return c.Responses[n%len(c.Responses)]
+	}
+	if n >= len(c.Responses) {
+		return c.Responses[len(c.Responses)-1] // stick on the last one
+	}
+	return c.Responses[n]
+}
+
+// weightedResponse picks one of responses at random, with a probability
+// proportional to its weight.
+func (h *ConversationsHandler) weightedResponse(responses []Response) Response {
+	total := 0
+	for _, r := range responses {
+		total += r.GetWeight()
+	}
+	n := h.randomSource().Intn(total)
+	for _, r := range responses {
+		if n -= r.GetWeight(); n < 0 {
+			return r
+		}
+	}
+	return responses[len(responses)-1]
+}
+
+// lockedRand is a rand.Rand that is safe for concurrent use.
+type lockedRand struct {
+	sync.Mutex
+	r *rand.Rand
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.Lock()
+	defer l.Unlock()
+	return l.r.Intn(n)
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.Lock()
+	defer l.Unlock()
+	return l.r.Float64()
+}
+
+func (l *lockedRand) NormFloat64() float64 {
+	l.Lock()
+	defer l.Unlock()
+	return l.r.NormFloat64()
+}
+
+// randomSource returns the source of randomness of the handler, using its seed,
+// if configured.
+func (h *ConversationsHandler) randomSource() *lockedRand {
+	h.Lock()
+	defer h.Unlock()
+	if h.random == nil {
+		seed := h.RandomSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		h.random = &lockedRand{r: rand.New(rand.NewSource(seed))}
+	}
+	return h.random
+}
+
+// GetResponseMode returns how a conversation with multiple responses selects the
+// response to serve, Sequence (default) serves them in order, staying on the
+// last one, Cycle starts over after the last one and Random picks one at random
+// according to their weight.
+func (c Conversation) GetResponseMode() string {
+	switch strings.ToLower(c.ResponseMode) {
+	case Cycle:
+		return Cycle
+	case Random:
+		return Random
+	default:
+		return Sequence
+	}
+}
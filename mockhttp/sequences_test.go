@@ -0,0 +1,135 @@
+package mockhttp
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func responses(bodies ...string) []Response {
+	r := make([]Response, len(bodies))
+	for i, b := range bodies {
+		r[i] = Response{StatusCode: 200, Body: b}
+	}
+	return r
+}
+
+func TestNextResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Conversation
+		want string // bodies served, in order
+	}{
+		{"single", Conversation{Name: "c", Response: Response{Body: "a"}}, "aaaaa"},
+		{"sequence", Conversation{Name: "c", Responses: responses("a", "b", "c")}, "abccc"},
+		{"sequence of one", Conversation{Name: "c", Responses: responses("a")}, "aaaaa"},
+		{"cycle", Conversation{Name: "c", ResponseMode: Cycle, Responses: responses("a", "b", "c")}, "abcab"},
+		{"cycle, upper case", Conversation{Name: "c", ResponseMode: "CYCLE", Responses: responses("a", "b")}, "ababa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler()
+			var served strings.Builder
+			for i := 0; i < len(tt.want); i++ {
+				served.WriteString(h.nextResponse(tt.c).Body)
+			}
+			if got := served.String(); got != tt.want {
+				t.Errorf("nextResponse() served %s, expected %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResetResponseSequences(t *testing.T) {
+	h := newTestHandler()
+	a := Conversation{Name: "a", Responses: responses("a1", "a2")}
+	b := Conversation{Name: "b", ResponseMode: Cycle, Responses: responses("b1", "b2")}
+	h.nextResponse(a)
+	h.nextResponse(a)
+	h.nextResponse(b)
+	if got, want := h.ResponseSequences(), map[string]int{"a": 2, "b": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ResponseSequences() %v, expected %v", got, want)
+	}
+
+	h.ResetResponseSequences("a")
+	if got := h.nextResponse(a).Body; got != "a1" {
+		t.Errorf("nextResponse() %s after reset, expected a1", got)
+	}
+	if got := h.nextResponse(b).Body; got != "b2" {
+		t.Errorf("nextResponse() %s of conversation not reset, expected b2", got)
+	}
+
+	h.ResetResponseSequences()
+	if got := h.ResponseSequences(); len(got) != 0 {
+		t.Errorf("ResponseSequences() %v after reset, expected none", got)
+	}
+	if got := h.nextResponse(b).Body; got != "b1" {
+		t.Errorf("nextResponse() %s after reset, expected b1", got)
+	}
+}
+
+// sequenceSource is a rand.Source making Intn(n) return the values in turn, as
+// long as they are less than n.
+type sequenceSource struct {
+	values []int64
+	next   int
+}
+
+func (s *sequenceSource) Int63() int64 {
+	v := s.values[s.next%len(s.values)]
+	s.next++
+	return v << 32
+}
+
+func (s *sequenceSource) Seed(int64) {}
+
+func TestWeightedResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []Response
+		draws     []int64 // drawn from [0, total weight)
+		want      string
+	}{
+		{"equal", responses("a", "b"), []int64{0, 1, 1, 0}, "abba"},
+		{"weighted", []Response{{Body: "a"}, {Body: "b", Weight: 3}}, []int64{0, 1, 2, 3}, "abbb"},
+		{"default weight", []Response{{Body: "a", Weight: 2}, {Body: "b"}, {Body: "c", Weight: 1}}, []int64{0, 1, 2, 3}, "aabc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler()
+			h.random = &lockedRand{r: rand.New(&sequenceSource{values: tt.draws})}
+			c := Conversation{Name: "c", ResponseMode: Random, Responses: tt.responses}
+			var served strings.Builder
+			for range tt.draws {
+				served.WriteString(h.nextResponse(c).Body)
+			}
+			if got := served.String(); got != tt.want {
+				t.Errorf("nextResponse() served %s, expected %s", got, tt.want)
+			}
+			if got := h.ResponseSequences(); len(got) != 0 {
+				t.Errorf("ResponseSequences() %v, random responses are not counted", got)
+			}
+		})
+	}
+}
+
+// TestWeightedResponse_Seeded checks that responses are picked in proportion to
+// their weight, and in the same order by handlers with the same seed.
+func TestWeightedResponse_Seeded(t *testing.T) {
+	h, other := newTestHandler(), newTestHandler()
+	c := Conversation{Name: "c", ResponseMode: Random, Responses: []Response{{Body: "a"}, {Body: "b", Weight: 3}}}
+	const picks = 10000
+	counts := make(map[string]int)
+	for i := 0; i < picks; i++ {
+		r := h.nextResponse(c)
+		if o := other.nextResponse(c); o.Body != r.Body {
+			t.Fatalf("pick %d: %s, expected %s from the same seed", i, r.Body, o.Body)
+		}
+		counts[r.Body]++
+	}
+	if share := float64(counts["b"]) / picks; math.Abs(share-0.75) > 0.02 {
+		t.Errorf("b picked %.3f of the time, expected 0.75", share)
+	}
+}
@@ -0,0 +1,198 @@
+package mockhttp
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const sseValues = "sse"
+
+// Chunk is a part of a streamed response body, it is flushed to the client as
+// soon as it is written.
+type Chunk struct {
+	Body  string        `yaml:"body"`
+	Delay ResponseDelay `yaml:"delay,omitempty"` // before the chunk is sent
+}
+
+// Event is a single Server-Sent Event, Id, Event and Data are templates, Data
+// spanning multiple lines is sent as multiple data fields.
+type Event struct {
+	Id    string        `yaml:"id,omitempty"`
+	Event string        `yaml:"event,omitempty"`
+	Data  string        `yaml:"data"`
+	Retry int           `yaml:"retry,omitempty"` // reconnection time in ms, sent to the client
+	Delay ResponseDelay `yaml:"delay,omitempty"` // before the event is sent
+}
+
+// EventStream is a text/event-stream response of Events. If Interval is set the
+// events are sent again after Interval, Repeat times or until the client
+// disconnects if Repeat is 0. The events are thus sent Repeat+1 times in all.
+type EventStream struct {
+	Events   []Event  `yaml:"events"`
+	Interval Duration `yaml:"interval,omitempty"`
+	Repeat   int      `yaml:"repeat,omitempty"`
+}
+
+// sseData is available to the templates of events as '.sse'.
+type sseData struct {
+	Number     int // number of the event within the stream, starting at 1
+	Repetition int // number of times the events have been repeated, starting at 0
+}
+
+// IsStreaming returns true if the response body is sent as chunks or events,
+// rather than as a single body.
+func (r Response) IsStreaming() bool {
+	return len(r.Chunks) > 0 || r.SSE != nil
+}
+
+// serveStream writes the status and headers of response, and then streams its
+// chunks or events, flushing each to the client.
+func (h *ConversationsHandler) serveStream(w http.ResponseWriter, r *http.Request, response Response, templateVars map[string]interface{}) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("streaming not supported by connection")
+	}
+	if response.SSE != nil {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.WriteHeader(response.StatusCode)
+	flusher.Flush()
+	if !response.BodyDelay.IsZero() {
+		if err := h.handleDelay(response.BodyDelay); err != nil {
+			h.Log.Errorf("While handling response body-delay: %v", err)
+		}
+	}
+	if response.SSE != nil {
+		return h.serveEvents(w, flusher, r, *response.SSE, templateVars)
+	}
+	return h.serveChunks(w, flusher, r, response, templateVars)
+}
+
+func (h *ConversationsHandler) serveChunks(w http.ResponseWriter, flusher http.Flusher, r *http.Request, response Response, templateVars map[string]interface{}) error {
+	buf := &bytes.Buffer{}
+	for i, c := range response.Chunks {
+		if err := h.handleDelay(c.Delay); err != nil {
+			h.Log.Errorf("While handling chunk delay: %v", err)
+		}
+		if r.Context().Err() != nil {
+			h.Log.Debugf("client disconnected after %d chunk(s)", i)
+			return nil
+		}
+		buf.Reset()
+		if response.RawBody {
+			buf.WriteString(c.Body)
+		} else {
+			tmpl, err := h.template("chunk", c.Body)
+			if err != nil {
+				return err
+			}
+			if err := tmpl.Execute(buf, templateVars); err != nil {
+				return err
+			}
+		}
+		if buf.Len() == 0 {
+			continue // an empty write would not produce a chunk
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		flusher.Flush()
+	}
+	return nil
+}
+
+type eventTemplates struct {
+	id, event, data *template.Template
+}
+
+func (h *ConversationsHandler) serveEvents(w http.ResponseWriter, flusher http.Flusher, r *http.Request, stream EventStream, templateVars map[string]interface{}) error {
+	templates := make([]eventTemplates, len(stream.Events))
+	for i, e := range stream.Events {
+		var err error
+		if templates[i].id, err = h.template("id", e.Id); err != nil {
+			return err
+		}
+		if templates[i].event, err = h.template("event", e.Event); err != nil {
+			return err
+		}
+		if templates[i].data, err = h.template("data", e.Data); err != nil {
+			return err
+		}
+	}
+
+	data := &sseData{}
+	templateVars[sseValues] = data
+	for {
+		for i, e := range stream.Events {
+			if err := h.handleDelay(e.Delay); err != nil {
+				h.Log.Errorf("While handling event delay: %v", err)
+			}
+			if r.Context().Err() != nil {
+				h.Log.Debugf("client disconnected after %d event(s)", data.Number)
+				return nil
+			}
+			data.Number++
+			frame, err := templates[i].frame(e.Retry, templateVars)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(frame); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+		if stream.Interval <= 0 || (stream.Repeat > 0 && data.Repetition >= stream.Repeat) {
+			return nil
+		}
+		select {
+		case <-r.Context().Done():
+			h.Log.Debugf("client disconnected after %d event(s)", data.Number)
+			return nil
+		case <-time.After(time.Duration(stream.Interval)):
+		}
+		data.Repetition++
+	}
+}
+
+// frame executes the templates of an event into a text/event-stream frame.
+func (t eventTemplates) frame(retry int, templateVars map[string]interface{}) ([]byte, error) {
+	execute := func(tmpl *template.Template) (string, error) {
+		b := &strings.Builder{}
+		err := tmpl.Execute(b, templateVars)
+		return b.String(), err
+	}
+	frame := &bytes.Buffer{}
+	id, err := execute(t.id)
+	if err != nil {
+		return nil, err
+	}
+	if id != "" {
+		fmt.Fprintf(frame, "id: %s\n", id)
+	}
+	event, err := execute(t.event)
+	if err != nil {
+		return nil, err
+	}
+	if event != "" {
+		fmt.Fprintf(frame, "event: %s\n", event)
+	}
+	if retry > 0 {
+		fmt.Fprintf(frame, "retry: %d\n", retry)
+	}
+	data, err := execute(t.data)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(data, "\n"), "\n") {
+		fmt.Fprintf(frame, "data: %s\n", line)
+	}
+	frame.WriteString("\n")
+	return frame.Bytes(), nil
+}
@@ -0,0 +1,90 @@
+package mockhttp
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// flushRecorder is a httptest.ResponseRecorder, that keeps what was written
+// between flushes.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed []string
+	last    int
+}
+
+func (r *flushRecorder) Flush() {
+	if body := r.Body.String(); len(body) > r.last {
+		r.flushed = append(r.flushed, body[r.last:])
+		r.last = len(body)
+	}
+	r.ResponseRecorder.Flush()
+}
+
+// stream serves GET / by h, and returns what was written between flushes.
+func stream(h *ConversationsHandler) *flushRecorder {
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	return w
+}
+
+func TestServeStream_Chunks(t *testing.T) {
+	h := newTestHandler(Conversation{Name: "chunks", Response: Response{StatusCode: 200, Chunks: []Chunk{
+		{Body: "one\n"},
+		{Body: `{{ if false }}skipped{{ end }}`},
+		{Body: "{{ .request.Path }}\n", Delay: ResponseDelay{Distribution: Fixed, Mean: ms(1)}},
+	}}})
+	w := stream(h)
+	if want := []string{"one\n", "/\n"}; !reflect.DeepEqual(w.flushed, want) {
+		t.Errorf("flushed %q, expected %q", w.flushed, want)
+	}
+	if w.Code != 200 {
+		t.Errorf("status %d, expected 200", w.Code)
+	}
+}
+
+func TestServeStream_Events(t *testing.T) {
+	h := newTestHandler(Conversation{Name: "events", Response: Response{StatusCode: 200, SSE: &EventStream{
+		Events: []Event{
+			{Id: "{{ .sse.Number }}", Event: "update", Retry: 1000, Data: "first\nsecond\n"},
+			{Data: "repetition {{ .sse.Repetition }}"},
+		},
+		Interval: Duration(time.Millisecond),
+		Repeat:   1,
+	}}})
+	w := stream(h)
+	want := []string{
+		"id: 1\nevent: update\nretry: 1000\ndata: first\ndata: second\n\n",
+		"data: repetition 0\n\n",
+		"id: 3\nevent: update\nretry: 1000\ndata: first\ndata: second\n\n",
+		"data: repetition 1\n\n",
+	}
+	if !reflect.DeepEqual(w.flushed, want) {
+		t.Errorf("flushed %q, expected %q", w.flushed, want)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type %s, expected text/event-stream", got)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "no-cache" {
+		t.Errorf("Cache-Control %s, expected no-cache", got)
+	}
+}
+
+func TestServeStream_EventsNotRepeated(t *testing.T) {
+	h := newTestHandler(Conversation{Name: "events", Response: Response{StatusCode: 200,
+		SSE: &EventStream{Events: []Event{{Data: "once"}}, Repeat: 3}}})
+	if w := stream(h); !reflect.DeepEqual(w.flushed, []string{"data: once\n\n"}) {
+		t.Errorf("flushed %q, expected the events sent once without interval", w.flushed)
+	}
+}
+
+// TestServeStream_Journal checks that the streamed body is kept in the journal.
+func TestServeStream_Journal(t *testing.T) {
+	h := newTestHandler(Conversation{Name: "chunks", Response: Response{StatusCode: 200, Chunks: []Chunk{{Body: "a"}, {Body: "b"}}}})
+	stream(h)
+	if e := h.Journal()[0]; e.ResponseBody != "ab" || e.ResponseSize != 2 || e.Status != 200 {
+		t.Errorf("journal entry %+v, expected the whole streamed body", e)
+	}
+}
@@ -1,9 +1,16 @@
 package mockhttp
 
 import (
+	"github.com/thorsager/mockdev/jsonexp"
+	"github.com/thorsager/mockdev/templatefuncs"
+	"mime"
 	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"regexp"
 	"strings"
+	"text/template"
 )
 
 const cfg = "cfg"
@@ -12,6 +19,50 @@ const run = "run"
 const envPrefix = "MOCKDEV_"
 const currentTime = "currentTime"
 const currentTimeGMT = "currentTime_GMT"
+const jsonValues = "json"
+const xmlValues = "xml"
+const hostValues = "host"
+const pathValues = "path"
+const queryValues = "query"
+const headerValues = "headers"
+const bodyValues = "body"
+const requestInfo = "request"
+
+var nonVarChars = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// matchVarName returns the name of the template variable, and script environment
+// variable, holding the value matched by the path of a json- or xml-matcher.
+// ex. the value of json-matcher '$.ports[0].id' is available as 'json_ports_0_id',
+// and the named group 'id' of the path as 'path_id'.
+func matchVarName(prefix string, path string) string {
+	return prefix + "_" + strings.Trim(nonVarChars.ReplaceAllString(path, "_"), "_")
+}
+
+// namedGroups returns the named groups of matches, found by re.
+func namedGroups(re *regexp.Regexp, matches []string) map[string]string {
+	groups := make(map[string]string)
+	if matches == nil {
+		return groups
+	}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			groups[name] = matches[i]
+		}
+	}
+	return groups
+}
+
+// setNamedGroups makes groups available to templates as '.<prefix>.<name>', and
+// to scripts as '<prefix>_<name>'.
+func setNamedGroups(templateVars map[string]interface{}, prefix string, groups map[string]string) {
+	if len(groups) == 0 {
+		return
+	}
+	for name, v := range groups {
+		templateVars[matchVarName(prefix, name)] = v
+	}
+	templateVars[prefix] = groups
+}
 
 type templateData map[string]interface{}
 type envData map[string]string
@@ -71,6 +122,42 @@ func listLocalAddresses() ([]string, []string, error) {
 	return ipv4, ipv6, nil
 }
 
+// createRequestData returns the request r, with its body, as template data. The
+// body is parsed as JSON if valid JSON, and as a form if form encoded.
+func createRequestData(r *http.Request, body []byte) templateData {
+	cookies := make(map[string]string)
+	for _, c := range r.Cookies() {
+		cookies[c.Name] = c.Value
+	}
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteIP = r.RemoteAddr
+	}
+	td := templateData{
+		"Method":     r.Method,
+		"Host":       r.Host,
+		"Path":       r.URL.Path,
+		"RawQuery":   r.URL.RawQuery,
+		"Query":      r.URL.Query(),
+		"Headers":    r.Header,
+		"Cookies":    cookies,
+		"Body":       string(body),
+		"JSON":       nil,
+		"Form":       url.Values{},
+		"RemoteAddr": r.RemoteAddr,
+		"RemoteIP":   remoteIP,
+	}
+	if doc, err := jsonexp.Decode(body); err == nil {
+		td["JSON"] = doc
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == "application/x-www-form-urlencoded" {
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			td["Form"] = form
+		}
+	}
+	return td
+}
+
 func createEnvData() envData {
 	evd := make(envData)
 	for _, tuple := range os.Environ() {
@@ -81,3 +168,12 @@ func createEnvData() envData {
 	}
 	return evd
 }
+
+// templateFuncs are the functions available to all templates.
+var templateFuncs = templatefuncs.Funcs()
+
+// parseTemplate parses text as a response template, all templates served must
+// be parsed using parseTemplate.
+func parseTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
@@ -0,0 +1,44 @@
+package mockhttp
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTemplate_Request(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		template    string
+		want        string
+	}{
+		{"request line", "", "", `{{ .request.Method }} {{ .request.Host }}{{ .request.Path }}?{{ .request.RawQuery }}`,
+			"POST device.example.com/ports/1?page=2&page=3"},
+		{"query", "", "", `{{ .request.Query.Get "page" }} {{ index .request.Query.page 1 }}`, "2 3"},
+		{"headers and cookies", "", "", `{{ .request.Headers.Get "Accept" }} {{ .request.Cookies.session }}`, "text/plain abc"},
+		{"body", "raw body", "text/plain", `{{ .request.Body }}`, "raw body"},
+		{"json", `{"port": {"id": 7}}`, "application/json", `{{ .request.JSON.port.id }}`, "7"},
+		{"not json", "raw body", "text/plain", `{{ with .request.JSON }}json{{ else }}none{{ end }}`, "none"},
+		{"form", "a=1&b=two", "application/x-www-form-urlencoded", `{{ .request.Form.Get "b" }}`, "two"},
+		{"no form", "a=1&b=two", "text/plain", `{{ len .request.Form }}`, "0"},
+		{"remote", "", "", `{{ .request.RemoteIP }} {{ .request.RemoteAddr }}`, "192.0.2.1 192.0.2.1:1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(Conversation{Name: "c", Response: Response{StatusCode: 200, Body: tt.template}})
+			r := httptest.NewRequest("POST", "http://device.example.com/ports/1?page=2&page=3", strings.NewReader(tt.body))
+			r.Header.Set("Accept", "text/plain")
+			r.Header.Set("Cookie", "session=abc")
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("rendered %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
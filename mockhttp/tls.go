@@ -0,0 +1,219 @@
+package mockhttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const ClientAuthRequest = "request"
+const ClientAuthRequire = "require"
+const ClientAuthVerifyIfGiven = "verify-if-given"
+const ClientAuthRequireAndVerify = "require-and-verify"
+
+// Headers set on requests carrying a client certificate, so that it can be
+// matched using header-matchers. Any such headers sent by the client are removed.
+const ClientCertSubjectHeader = "X-Client-Cert-Subject"
+const ClientCertIssuerHeader = "X-Client-Cert-Issuer"
+
+const tlsInfo = "tls"
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// DefaultSelfSignedNames are the names of the generated certificate, if no
+// certificate and no names are configured.
+var DefaultSelfSignedNames = []string{"localhost", "127.0.0.1"}
+
+type TLSConfiguration struct {
+	CertFile      string   `yaml:"cert-file,omitempty"`
+	KeyFile       string   `yaml:"key-file,omitempty"`
+	Cert          string   `yaml:"cert,omitempty"`        // PEM encoded
+	Key           string   `yaml:"key,omitempty"`         // PEM encoded
+	SelfSigned    []string `yaml:"self-signed,omitempty"` // DNS names and IPs of generated certificate
+	ClientAuth    string   `yaml:"client-auth,omitempty"` // possible: "", "request", "require", "verify-if-given", "require-and-verify"
+	ClientCAFiles []string `yaml:"client-ca-files,omitempty"`
+	ClientCAs     []string `yaml:"client-cas,omitempty"` // PEM encoded
+}
+
+// Certificate returns the configured certificate, either loaded from files,
+// from inline PEM, or generated and self-signed.
+func (c *TLSConfiguration) Certificate() (tls.Certificate, error) {
+	switch {
+	case c.CertFile != "" || c.KeyFile != "":
+		return tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	case c.Cert != "" || c.Key != "":
+		return tls.X509KeyPair([]byte(c.Cert), []byte(c.Key))
+	case len(c.SelfSigned) > 0:
+		return GenerateCertificate(c.SelfSigned...)
+	default:
+		return GenerateCertificate(DefaultSelfSignedNames...)
+	}
+}
+
+// ClientAuthType returns the tls.ClientAuthType of the configured client-auth.
+func (c *TLSConfiguration) ClientAuthType() (tls.ClientAuthType, error) {
+	switch strings.ToLower(c.ClientAuth) {
+	case "":
+		return tls.NoClientCert, nil
+	case ClientAuthRequest:
+		return tls.RequestClientCert, nil
+	case ClientAuthRequire:
+		return tls.RequireAnyClientCert, nil
+	case ClientAuthVerifyIfGiven:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthRequireAndVerify:
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("invalid client-auth '%s'", c.ClientAuth)
+	}
+}
+
+// ClientCAPool returns a pool of the configured client CAs, or nil if none
+// are configured.
+func (c *TLSConfiguration) ClientCAPool() (*x509.CertPool, error) {
+	if len(c.ClientCAFiles) == 0 && len(c.ClientCAs) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	for _, f := range c.ClientCAFiles {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in '%s'", f)
+		}
+	}
+	for i, pem := range c.ClientCAs {
+		if !pool.AppendCertsFromPEM([]byte(pem)) {
+			return nil, fmt.Errorf("no certificates found in client-cas[%d]", i)
+		}
+	}
+	return pool, nil
+}
+
+// ServerConfig returns a tls.Config serving the configured certificate, and
+// requesting client certificates as configured. Only HTTP/1.1 is offered, as
+// faults and websockets take over the connection, which HTTP/2 does not allow.
+func (c *TLSConfiguration) ServerConfig() (*tls.Config, error) {
+	cert, err := c.Certificate()
+	if err != nil {
+		return nil, err
+	}
+	clientAuth, err := c.ClientAuthType()
+	if err != nil {
+		return nil, err
+	}
+	pool, err := c.ClientCAPool()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuth,
+		ClientCAs:    pool,
+		NextProtos:   []string{"http/1.1"},
+	}, nil
+}
+
+// ConfigureTLS configures server to serve HTTPS, see Configuration.ServerConfig.
+// HTTP/2 is disabled, as faults and websockets take over the connection, which
+// HTTP/2 does not allow.
+func (c *Configuration) ConfigureTLS(server *http.Server) error {
+	config, err := c.ServerConfig()
+	if err != nil {
+		return err
+	}
+	server.TLSConfig = config
+	server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	return nil
+}
+
+// GenerateCertificate returns a new self-signed certificate, valid for the
+// passed names, which may be DNS names or IPs.
+func GenerateCertificate(names ...string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"mockdev"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, n := range names {
+		if ip := net.ParseIP(n); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, n)
+		}
+	}
+	if len(names) > 0 {
+		template.Subject.CommonName = names[0]
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// setClientCertHeaders replaces any client certificate headers of the request
+// with those of the client certificate presented, if any.
+func setClientCertHeaders(r *http.Request) {
+	r.Header.Del(ClientCertSubjectHeader)
+	r.Header.Del(ClientCertIssuerHeader)
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		cert := r.TLS.PeerCertificates[0]
+		r.Header.Set(ClientCertSubjectHeader, cert.Subject.String())
+		r.Header.Set(ClientCertIssuerHeader, cert.Issuer.String())
+	}
+}
+
+// createTLSData returns the TLS information of the request, available in
+// templates as .tls, or nil if the request was not made using TLS.
+func createTLSData(r *http.Request) templateData {
+	if r.TLS == nil {
+		return nil
+	}
+	td := templateData{
+		"ServerName": r.TLS.ServerName,
+		"Version":    tlsVersions[r.TLS.Version],
+	}
+	if len(r.TLS.PeerCertificates) > 0 {
+		cert := r.TLS.PeerCertificates[0]
+		td["ClientSubject"] = cert.Subject.String()
+		td["ClientCommonName"] = cert.Subject.CommonName
+		td["ClientIssuer"] = cert.Issuer.String()
+		td["ClientSerial"] = cert.SerialNumber.String()
+	}
+	return td
+}
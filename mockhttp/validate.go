@@ -0,0 +1,490 @@
+package mockhttp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/thorsager/mockdev/headerexp"
+	"github.com/thorsager/mockdev/jsonexp"
+	"github.com/thorsager/mockdev/queryexp"
+	"github.com/thorsager/mockdev/validation"
+	"github.com/thorsager/mockdev/xmlexp"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Validate checks the configuration, and all conversations in it and in its
+// conversation-files, for errors that would otherwise first show when serving.
+// Errors found in conversation-files are located in those files.
+func (c *Configuration) Validate() validation.Errors {
+	var errs validation.Errors
+	if _, _, err := net.SplitHostPort(c.BindAddr); err != nil {
+		errs.Add("bind-addr", c.BindAddr, err)
+	}
+	if c.TLS != nil {
+		errs = append(errs, c.TLS.validate().Within("tls", "tls:")...)
+	}
+	if c.Passthrough != nil {
+		errs = append(errs, c.Passthrough.validate().Within("passthrough", "passthrough:")...)
+	}
+	if c.Unmatched != nil {
+		errs = append(errs, c.Unmatched.validate().Within("unmatched", "unmatched:")...)
+	}
+	names := make(map[string]bool)
+	errs = append(errs, validateConversations(names, c.Conversations, c.ConversationFiles, nil)...)
+	for i, v := range c.VirtualHosts {
+		errs = append(errs, v.validate(names, c.TLS != nil).Within(fmt.Sprintf("virtual-hosts[%d]", i), v.Host)...)
+	}
+	return errs
+}
+
+// validateConversations validates conversations, and the conversations found in
+// files, the conversations are scoped by scope, if set.
+func validateConversations(names map[string]bool, conversations []Conversation, files []string, scope func(Conversation) Conversation) validation.Errors {
+	var errs validation.Errors
+	for i, conv := range conversations {
+		var cerrs validation.Errors
+		cerrs = append(cerrs, conv.Validate()...)
+		cerrs = append(cerrs, checkScoped(names, conv, scope)...)
+		errs = append(errs, cerrs.Within(conversationField(i, conv), conv.Name)...)
+	}
+	for _, cf := range files {
+		data, err := ioutil.ReadFile(cf)
+		if err != nil {
+			errs.AddAt(cf, 0, "", err)
+			continue
+		}
+		conversations, err := DecodeConversationFile(cf)
+		if err != nil {
+			errs.AddAt(cf, 0, "", err)
+			continue
+		}
+		for i, conv := range conversations {
+			var cerrs validation.Errors
+			cerrs = append(cerrs, conv.Validate()...)
+			cerrs = append(cerrs, checkScoped(names, conv, scope)...)
+			errs = append(errs, cerrs.Within(conversationField(i, conv), conv.Name).Locate(cf, data)...)
+		}
+	}
+	return errs
+}
+
+func conversationField(i int, c Conversation) string {
+	if c.Name == "" {
+		return fmt.Sprintf("conversations[%d]", i)
+	}
+	return fmt.Sprintf("conversations[%s]", c.Name)
+}
+
+// checkScoped checks that conv can be scoped by scope, if set, and that the
+// name of the scoped conversation is not a duplicate.
+func checkScoped(names map[string]bool, conv Conversation, scope func(Conversation) Conversation) validation.Errors {
+	if scope == nil {
+		return checkDuplicate(names, conv)
+	}
+	var errs validation.Errors
+	if conv.Request.HostMatcher != "" {
+		errs.Add("request.host-matcher", conv.Request.HostMatcher, fmt.Errorf("can not be used in a virtual host, its host is matched"))
+	}
+	return append(errs, checkDuplicate(names, scope(conv))...)
+}
+
+func checkDuplicate(names map[string]bool, c Conversation) validation.Errors {
+	var errs validation.Errors
+	if names[c.Name] && c.Name != "" {
+		errs.Add("name", c.Name, fmt.Errorf("duplicate conversation name '%s'", c.Name))
+	}
+	names[c.Name] = true
+	return errs
+}
+
+// Validate checks the conversation for errors that would otherwise first show
+// when it is matched or served, such as invalid regular expressions or
+// templates.
+func (c Conversation) Validate() validation.Errors {
+	var errs validation.Errors
+	if c.Name == "" {
+		errs.Add("name", "", fmt.Errorf("missing name"))
+	}
+	switch strings.ToLower(c.BreakOn) {
+	case "", Match, NoMatch:
+	default:
+		errs.Add("break-on", c.BreakOn, fmt.Errorf("invalid value '%s', must be '%s' or '%s'", c.BreakOn, Match, NoMatch))
+	}
+	if c.Scenario == "" {
+		if c.RequiredState != "" {
+			errs.Add("required-state", c.RequiredState, fmt.Errorf("can not be used without scenario"))
+		}
+		if c.NewState != "" {
+			errs.Add("new-state", c.NewState, fmt.Errorf("can not be used without scenario"))
+		}
+	}
+	errs = append(errs, c.Request.validate().Within("request", "")...)
+	switch strings.ToLower(c.ResponseMode) {
+	case "", Sequence, Cycle, Random:
+	default:
+		errs.Add("response-mode", c.ResponseMode, fmt.Errorf("invalid value '%s', must be '%s', '%s' or '%s'", c.ResponseMode, Sequence, Cycle, Random))
+	}
+	if c.WebSocket != nil {
+		if len(c.Responses) > 0 {
+			errs.Add("responses", "responses:", fmt.Errorf("responses can not be used with websocket"))
+		}
+		errs = append(errs, c.WebSocket.validate().Within("websocket", "websocket:")...)
+	} else if len(c.Responses) == 0 {
+		errs = append(errs, c.Response.validate().Within("response", "")...)
+	}
+	for i, r := range c.Responses {
+		errs = append(errs, r.validate().Within(fmt.Sprintf("responses[%d]", i), "")...)
+	}
+	return errs
+}
+
+func (r Request) validate() validation.Errors {
+	var errs validation.Errors
+	if r.HostMatcher != "" {
+		if _, err := regexp.Compile(r.HostMatcher); err != nil {
+			errs.Add("host-matcher", r.HostMatcher, err)
+		}
+	}
+	if r.UrlMatcher.Path != "" {
+		if _, err := regexp.Compile(r.UrlMatcher.Path); err != nil {
+			errs.Add("url-matcher.path", r.UrlMatcher.Path, err)
+		}
+	}
+	if r.UrlMatcher.PathTemplate != "" {
+		if r.UrlMatcher.Path != "" {
+			errs.Add("url-matcher.path-template", r.UrlMatcher.PathTemplate, fmt.Errorf("can not be used with path"))
+		} else if _, err := compilePathTemplate(r.UrlMatcher.PathTemplate); err != nil {
+			errs.Add("url-matcher.path-template", r.UrlMatcher.PathTemplate, err)
+		}
+	}
+	if r.UrlMatcher.Query != "" {
+		if _, err := queryexp.Compile(r.UrlMatcher.Query); err != nil {
+			errs.Add("url-matcher.query", r.UrlMatcher.Query, err)
+		} else if _, err := regexp.Compile(r.UrlMatcher.Query); err != nil {
+			// also matched against the raw query, for q<n>
+			errs.Add("url-matcher.query", r.UrlMatcher.Query, err)
+		}
+	}
+	if r.MethodMatcher != "" {
+		if _, err := regexp.Compile(r.MethodMatcher); err != nil {
+			errs.Add("method-matcher", r.MethodMatcher, err)
+		}
+	}
+	switch strings.ToLower(r.HeaderMatchType) {
+	case "", Contains, IfPresent:
+	default:
+		errs.Add("header-match-type", r.HeaderMatchType, fmt.Errorf("invalid value '%s', must be '%s' or '%s'", r.HeaderMatchType, Contains, IfPresent))
+	}
+	for i, hm := range r.HeaderMatchers {
+		if _, err := headerexp.Compile(hm); err != nil {
+			errs.Add(fmt.Sprintf("header-matchers[%d]", i), hm, err)
+		}
+	}
+	if r.BodyMatcher != "" {
+		if _, err := regexp.Compile(r.BodyMatcher); err != nil {
+			errs.Add("body-matcher", r.BodyMatcher, err)
+		}
+	}
+	if len(r.JsonMatchers) > 0 {
+		if _, err := jsonexp.Compile(r.JsonMatchers); err != nil {
+			errs.Add("json-matchers", "json-matchers:", err)
+		}
+	}
+	if len(r.XmlMatchers) > 0 {
+		if _, err := xmlexp.Compile(r.XmlMatchers, r.XmlNamespaces); err != nil {
+			errs.Add("xml-matchers", "xml-matchers:", err)
+		}
+	}
+	return errs
+}
+
+func (r Response) validate() validation.Errors {
+	var errs validation.Errors
+	if len(r.Script) == 0 && (r.StatusCode < 100 || r.StatusCode > 999) {
+		errs.Add("status-code", "status-code:", fmt.Errorf("invalid status-code %d", r.StatusCode))
+	}
+	for i, h := range r.Headers {
+		field := fmt.Sprintf("headers[%d]", i)
+		if !strings.Contains(h, ":") {
+			errs.Add(field, h, fmt.Errorf("invalid header '%s', must be '<name>: <value>'", h))
+		}
+		if _, err := parseTemplate("header", h); err != nil {
+			errs.Add(field, h, err)
+		}
+	}
+	if r.BodyFile != "" {
+		if body, err := ioutil.ReadFile(r.BodyFile); err != nil {
+			errs.Add("body-file", r.BodyFile, err)
+		} else if _, err := parseTemplate("body", string(body)); err != nil && !r.RawBody {
+			errs.Add("body-file", r.BodyFile, err)
+		}
+	} else if _, err := parseTemplate("body", r.Body); err != nil && !r.RawBody {
+		errs.Add("body", r.Body, err)
+	}
+	if r.Fault != nil {
+		if len(r.Script) > 0 {
+			errs.Add("fault", "fault:", fmt.Errorf("fault can not be used with script"))
+		}
+		errs = append(errs, r.Fault.validate().Within("fault", "fault:")...)
+	}
+	if r.IsStreaming() {
+		if len(r.Chunks) > 0 && r.SSE != nil {
+			errs.Add("chunks", "chunks:", fmt.Errorf("chunks can not be used with sse"))
+		}
+		if r.Body != "" || r.BodyFile != "" {
+			errs.Add("body", "body:", fmt.Errorf("body can not be used with chunks or sse"))
+		}
+		if len(r.Script) > 0 || r.Fault != nil {
+			errs.Add("script", "script:", fmt.Errorf("script and fault can not be used with chunks or sse"))
+		}
+	}
+	for i, c := range r.Chunks {
+		errs = append(errs, c.validate(r.RawBody).Within(fmt.Sprintf("[%d]", i), c.Body).Within("chunks", "chunks:")...)
+	}
+	if r.SSE != nil {
+		errs = append(errs, r.SSE.validate().Within("sse", "sse:")...)
+	}
+	if r.Weight < 0 {
+		errs.Add("weight", "weight:", fmt.Errorf("must not be negative"))
+	}
+	errs = append(errs, r.Delay.validate().Within("delay", "delay:")...)
+	errs = append(errs, r.BodyDelay.validate().Within("body-delay", "body-delay:")...)
+	return errs
+}
+
+func (d ResponseDelay) validate() validation.Errors {
+	var errs validation.Errors
+	if d.Min < 0 {
+		errs.Add("min", "min:", fmt.Errorf("must not be negative"))
+	}
+	if d.Max < 0 {
+		errs.Add("max", "max:", fmt.Errorf("must not be negative"))
+	}
+	if d.Mean < 0 {
+		errs.Add("mean", "mean:", fmt.Errorf("must not be negative"))
+	}
+	if d.StdDev < 0 {
+		errs.Add("std-dev", "std-dev:", fmt.Errorf("must not be negative"))
+	}
+	if d.Max != 0 && d.Max < d.Min {
+		errs.Add("max", "max:", fmt.Errorf("max (%s) must not be less than min (%s)", d.Max, d.Min))
+	}
+	switch d.GetDistribution() {
+	case Fixed, Uniform, Normal:
+	case LogNormal:
+		if d.Mean <= 0 {
+			errs.Add("mean", "mean:", fmt.Errorf("must be positive for distribution '%s'", LogNormal))
+		}
+	case Percentiles:
+		if len(d.Percentiles) == 0 {
+			errs.Add("percentiles", "distribution:", fmt.Errorf("missing percentiles"))
+		}
+		var last Duration
+		keys := make([]float64, 0, len(d.Percentiles))
+		for k := range d.Percentiles {
+			keys = append(keys, k)
+		}
+		sort.Float64s(keys)
+		for _, k := range keys {
+			if k <= 0 || k > 100 {
+				errs.Add("percentiles", "percentiles:", fmt.Errorf("invalid percentile %g, must be in (0,100]", k))
+			}
+			if d.Percentiles[k] < last {
+				errs.Add("percentiles", "percentiles:", fmt.Errorf("percentile %g (%s) must not be less than lower percentiles", k, d.Percentiles[k]))
+			}
+			last = d.Percentiles[k]
+		}
+	default:
+		errs.Add("distribution", d.Distribution, fmt.Errorf("invalid value '%s', must be one of %s", d.Distribution,
+			strings.Join([]string{Fixed, Uniform, Normal, LogNormal, Percentiles}, ", ")))
+	}
+	return errs
+}
+
+// validate checks the virtual host, and its conversations, names are the names
+// of the conversations validated so far, and serviceTLS tells if the service is
+// serving HTTPS.
+func (v VirtualHost) validate(names map[string]bool, serviceTLS bool) validation.Errors {
+	var errs validation.Errors
+	if v.Host == "" {
+		errs.Add("host", "", fmt.Errorf("missing host"))
+	} else if _, err := regexp.Compile(v.Host); err != nil {
+		errs.Add("host", v.Host, err)
+	}
+	if v.TLS != nil {
+		if !serviceTLS {
+			errs.Add("tls", "tls:", fmt.Errorf("tls of a virtual host requires tls of the service"))
+		} else {
+			errs = append(errs, v.TLS.validate().Within("tls", "tls:")...)
+		}
+	}
+	errs = append(errs, validateConversations(names, v.Conversations, v.ConversationFiles, v.scope)...)
+	return errs
+}
+
+func (u UnmatchedResponse) validate() validation.Errors {
+	var errs validation.Errors
+	if u.StatusCode != 0 && (u.StatusCode < 100 || u.StatusCode > 999) {
+		errs.Add("status-code", "status-code:", fmt.Errorf("invalid status-code %d", u.StatusCode))
+	}
+	if u.Closest < 0 {
+		errs.Add("closest", "closest:", fmt.Errorf("invalid value %d, must not be negative", u.Closest))
+	}
+	return errs
+}
+
+func (p PassthroughConfiguration) validate() validation.Errors {
+	var errs validation.Errors
+	if _, err := parseUpstream(p.Upstream); err != nil {
+		errs.Add("upstream", p.Upstream, err)
+	}
+	if p.Record != nil {
+		if p.Record.File == "" {
+			errs.Add("record.file", "record:", fmt.Errorf("missing file"))
+		}
+		if p.Record.MaxInlineBody < 0 {
+			errs.Add("record.max-inline-body", "max-inline-body:", fmt.Errorf("invalid value %d, must not be negative", p.Record.MaxInlineBody))
+		}
+	}
+	return errs
+}
+
+func (t TLSConfiguration) validate() validation.Errors {
+	var errs validation.Errors
+	sources := 0
+	if t.CertFile != "" || t.KeyFile != "" {
+		sources++
+		if t.CertFile == "" || t.KeyFile == "" {
+			errs.Add("cert-file", "", fmt.Errorf("both cert-file and key-file must be configured"))
+		}
+	}
+	if t.Cert != "" || t.Key != "" {
+		sources++
+		if t.Cert == "" || t.Key == "" {
+			errs.Add("cert", "", fmt.Errorf("both cert and key must be configured"))
+		}
+	}
+	if len(t.SelfSigned) > 0 {
+		sources++
+	}
+	if sources > 1 {
+		errs.Add("", "", fmt.Errorf("only one of cert-file/key-file, cert/key or self-signed may be configured"))
+	} else if len(errs) == 0 {
+		if _, err := t.Certificate(); err != nil {
+			if t.CertFile != "" {
+				errs.Add("cert-file", t.CertFile, err)
+			} else {
+				errs.Add("cert", "cert:", err)
+			}
+		}
+	}
+	clientAuth, err := t.ClientAuthType()
+	if err != nil {
+		errs.Add("client-auth", t.ClientAuth, err)
+	}
+	pool, err := t.ClientCAPool()
+	if err != nil {
+		errs.Add("client-ca-files", "", err)
+	}
+	if pool == nil && (clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert) {
+		errs.Add("client-auth", t.ClientAuth, fmt.Errorf("client-auth '%s' requires client-ca-files or client-cas", t.ClientAuth))
+	}
+	return errs
+}
+
+func (f Fault) validate() validation.Errors {
+	var errs validation.Errors
+	switch strings.ToLower(f.Type) {
+	case FaultReset, FaultEmpty, FaultCloseAfter, FaultStall, FaultMalformed:
+	case FaultDrip:
+		if f.BytesPerSecond <= 0 {
+			errs.Add("bytes-per-second", "type:", fmt.Errorf("must be positive for fault '%s'", FaultDrip))
+		}
+	default:
+		errs.Add("type", "type:", fmt.Errorf("invalid value '%s', must be one of %s", f.Type,
+			strings.Join([]string{FaultReset, FaultEmpty, FaultCloseAfter, FaultStall, FaultDrip, FaultMalformed}, ", ")))
+	}
+	if f.Bytes < 0 {
+		errs.Add("bytes", "bytes:", fmt.Errorf("must not be negative"))
+	}
+	if f.Stall < 0 {
+		errs.Add("stall", "stall:", fmt.Errorf("must not be negative"))
+	}
+	return errs
+}
+
+func (c Chunk) validate(raw bool) validation.Errors {
+	var errs validation.Errors
+	if _, err := parseTemplate("chunk", c.Body); err != nil && !raw {
+		errs.Add("body", "", err)
+	}
+	errs = append(errs, c.Delay.validate().Within("delay", "delay:")...)
+	return errs
+}
+
+func (s EventStream) validate() validation.Errors {
+	var errs validation.Errors
+	if len(s.Events) == 0 {
+		errs.Add("events", "events:", fmt.Errorf("at least one event is required"))
+	}
+	for i, e := range s.Events {
+		field := fmt.Sprintf("events[%d]", i)
+		if _, err := parseTemplate("id", e.Id); err != nil {
+			errs.Add(field+".id", e.Id, err)
+		}
+		if _, err := parseTemplate("event", e.Event); err != nil {
+			errs.Add(field+".event", e.Event, err)
+		}
+		if _, err := parseTemplate("data", e.Data); err != nil {
+			errs.Add(field+".data", e.Data, err)
+		}
+		if strings.ContainsAny(e.Id+e.Event, "\r\n") {
+			errs.Add(field, "id:", fmt.Errorf("id and event must be a single line"))
+		}
+		if e.Retry < 0 {
+			errs.Add(field+".retry", "retry:", fmt.Errorf("must not be negative"))
+		}
+		errs = append(errs, e.Delay.validate().Within(field+".delay", "delay:")...)
+	}
+	if s.Interval < 0 {
+		errs.Add("interval", "interval:", fmt.Errorf("must not be negative"))
+	}
+	if s.Repeat < 0 {
+		errs.Add("repeat", "repeat:", fmt.Errorf("must not be negative"))
+	}
+	return errs
+}
+
+func (ws WebSocket) validate() validation.Errors {
+	var errs validation.Errors
+	for i, m := range ws.Messages {
+		field := fmt.Sprintf("messages[%d]", i)
+		if _, err := regexp.Compile(m.Matcher); err != nil {
+			errs.Add(field+".matcher", m.Matcher, err)
+		}
+		if _, err := parseTemplate("reply", m.Reply); err != nil {
+			errs.Add(field+".reply", m.Reply, err)
+		}
+		errs = append(errs, m.Delay.validate().Within(field+".delay", "delay:")...)
+	}
+	for i, p := range ws.Push {
+		field := fmt.Sprintf("push[%d]", i)
+		if _, err := parseTemplate("body", p.Body); err != nil {
+			errs.Add(field+".body", p.Body, err)
+		}
+		if p.Interval < 0 {
+			errs.Add(field+".interval", "interval:", fmt.Errorf("must not be negative"))
+		}
+		if p.Repeat < 0 {
+			errs.Add(field+".repeat", "repeat:", fmt.Errorf("must not be negative"))
+		}
+		errs = append(errs, p.Delay.validate().Within(field+".delay", "delay:")...)
+	}
+	if _, err := parseTemplate("no-match", ws.NoMatch); err != nil {
+		errs.Add("no-match", ws.NoMatch, err)
+	}
+	return errs
+}
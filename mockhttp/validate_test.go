@@ -0,0 +1,41 @@
+package mockhttp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConversationValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Conversation // named 'c', responding 200
+		want string       // substring of the error, empty if valid
+	}{
+		{"valid", Conversation{Request: Request{UrlMatcher: UrlMatcher{Path: "^/a$", Query: "a=^b$"}}}, ""},
+		{"path", Conversation{Request: Request{UrlMatcher: UrlMatcher{Path: "^/a("}}}, "request.url-matcher.path:"},
+		{"query", Conversation{Request: Request{UrlMatcher: UrlMatcher{Query: "a=("}}}, "request.url-matcher.query:"},
+		{"raw query", Conversation{Request: Request{UrlMatcher: UrlMatcher{Query: "a(=x"}}}, "request.url-matcher.query:"},
+		{"method", Conversation{Request: Request{MethodMatcher: "GET|("}}, "request.method-matcher:"},
+		{"scenario", Conversation{Scenario: "s", RequiredState: "a", NewState: "b"}, ""},
+		{"required-state", Conversation{RequiredState: "a"}, "required-state: can not be used without scenario"},
+		{"new-state", Conversation{NewState: "b"}, "new-state: can not be used without scenario"},
+		{"delay min", Conversation{Response: Response{Delay: ResponseDelay{Min: 1}}}, ""},
+		{"delay interval", Conversation{Response: Response{Delay: ResponseDelay{Min: 2, Max: 1}}}, "max (1ns) must not be less than min (2ns)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.c
+			c.Name, c.Response.StatusCode = "c", 200
+			errs := c.Validate()
+			if tt.want == "" {
+				if len(errs) > 0 {
+					t.Errorf("Validate() %v, expected no errors", errs)
+				}
+				return
+			}
+			if !strings.Contains(errs.Error(), tt.want) {
+				t.Errorf("Validate() %v, expected '%s'", errs, tt.want)
+			}
+		})
+	}
+}
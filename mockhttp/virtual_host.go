@@ -0,0 +1,100 @@
+package mockhttp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// VirtualHost scopes conversations to the requests for the hosts matched by
+// Host, so one service can serve several devices by their host name.
+type VirtualHost struct {
+	// Name, if set, prefixes the names of the conversations of the virtual host,
+	// '<name>:<conversation>', so the same conversation-files can be used by
+	// several virtual hosts.
+	Name              string            `yaml:"name,omitempty"`
+	Host              string            `yaml:"host"` // regular expression, used as host-matcher of all conversations
+	ConversationFiles []string          `yaml:"conversation-files,omitempty"`
+	Conversations     []Conversation    `yaml:"conversations,omitempty"`
+	TLS               *TLSConfiguration `yaml:"tls,omitempty"` // served to clients asking for a matching host (SNI)
+}
+
+// LoadConversations returns the conversations of the virtual host, and those
+// found in its conversation-files, scoped to the virtual host.
+func (v VirtualHost) LoadConversations() ([]Conversation, error) {
+	conversations := append([]Conversation(nil), v.Conversations...)
+	for _, cf := range v.ConversationFiles {
+		con, err := DecodeConversationFile(cf)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, con...)
+	}
+	for i := range conversations {
+		conversations[i] = v.scope(conversations[i])
+	}
+	return conversations, nil
+}
+
+// scope returns c, matching only requests for the virtual host. Any
+// host-matcher of c is replaced, which validation rejects.
+func (v VirtualHost) scope(c Conversation) Conversation {
+	if v.Name != "" {
+		c.Name = v.Name + ":" + c.Name
+	}
+	c.Request.HostMatcher = v.Host
+	return c
+}
+
+// requestHost returns the host of a request, without port, in lower case.
+func requestHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// ServerConfig returns a tls.Config serving the certificate, and requesting
+// client certificates, as configured by the TLS of the virtual host matching
+// the server name (SNI) asked for by the client, or as configured by the TLS of
+// the service, if no virtual host matches, or has TLS. TLS must be configured.
+func (c *Configuration) ServerConfig() (*tls.Config, error) {
+	config, err := c.TLS.ServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	type hostConfig struct {
+		host   *regexp.Regexp
+		config *tls.Config
+	}
+	var hosts []hostConfig
+	for _, v := range c.VirtualHosts {
+		if v.TLS == nil {
+			continue
+		}
+		host, err := regexp.Compile(v.Host)
+		if err != nil {
+			return nil, fmt.Errorf("virtual-host '%s': %v", v.Host, err)
+		}
+		hc, err := v.TLS.ServerConfig()
+		if err != nil {
+			return nil, fmt.Errorf("virtual-host '%s': %v", v.Host, err)
+		}
+		hosts = append(hosts, hostConfig{host: host, config: hc})
+	}
+	if len(hosts) == 0 {
+		return config, nil
+	}
+	config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		name := strings.ToLower(hello.ServerName)
+		for _, h := range hosts {
+			if name != "" && h.host.MatchString(name) {
+				return h.config, nil
+			}
+		}
+		return nil, nil // the service configuration
+	}
+	return config, nil
+}
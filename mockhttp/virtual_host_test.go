@@ -0,0 +1,176 @@
+package mockhttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// virtualHostTestConfiguration serves the same conversation for two routers,
+// and a conversation for any host.
+var virtualHostTestConfiguration = Configuration{
+	Conversations: []Conversation{
+		{Name: "status", Order: 1, Response: Response{StatusCode: 200, Body: "any"}},
+	},
+	VirtualHosts: []VirtualHost{
+		{Name: "router1", Host: `^(?P<device>router1)\.example\.com$`, Conversations: virtualHostTestConversations},
+		{Name: "router2", Host: `^(?P<device>router2)\.example\.com$`, Conversations: virtualHostTestConversations},
+	},
+}
+
+var virtualHostTestConversations = []Conversation{
+	{Name: "status", Request: Request{UrlMatcher: UrlMatcher{Path: "^/status$"}},
+		Response: Response{StatusCode: 200, Body: "{{ .host.device }}"}},
+}
+
+func TestVirtualHost_LoadConversations(t *testing.T) {
+	conversations, err := virtualHostTestConfiguration.LoadConversations()
+	if err != nil {
+		t.Fatalf("LoadConversations() %v", err)
+	}
+	var names, hosts []string
+	for _, c := range conversations {
+		names = append(names, c.Name)
+		hosts = append(hosts, c.Request.HostMatcher)
+	}
+	if want := []string{"router1:status", "router2:status", "status"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names %v, expected %v", names, want)
+	}
+	if want := []string{virtualHostTestConfiguration.VirtualHosts[0].Host, virtualHostTestConfiguration.VirtualHosts[1].Host, ""}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("host-matchers %v, expected %v", hosts, want)
+	}
+}
+
+func TestVirtualHost_Routing(t *testing.T) {
+	conversations, err := virtualHostTestConfiguration.LoadConversations()
+	if err != nil {
+		t.Fatalf("LoadConversations() %v", err)
+	}
+	h := newTestHandler(conversations...)
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"http://router1.example.com/status", "router1"},
+		{"http://ROUTER2.example.com:8080/status", "router2"},
+		{"http://router3.example.com/status", "any"},
+		{"http://router1.example.com/other", "any"},
+	}
+	for _, tt := range tests {
+		if got := serve(h, "GET", tt.target); got != tt.want {
+			t.Errorf("GET %s = %s, expected %s", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestVirtualHost_Validate(t *testing.T) {
+	config := Configuration{BindAddr: "127.0.0.1:0", VirtualHosts: []VirtualHost{
+		{Host: "^a$", Conversations: virtualHostTestConversations},
+		{Host: "^b$", Conversations: virtualHostTestConversations},
+	}}
+	if errs := config.Validate(); len(errs) != 1 || !strings.Contains(errs[0].Error(), "duplicate") {
+		t.Errorf("Validate() %v, expected the unnamed virtual hosts to duplicate names", errs)
+	}
+	config.VirtualHosts[0].Name, config.VirtualHosts[1].Name = "a", "b"
+	if errs := config.Validate(); len(errs) != 0 {
+		t.Errorf("Validate() %v, expected no errors of named virtual hosts", errs)
+	}
+
+	config.VirtualHosts[0].Conversations = []Conversation{{Name: "status", Request: Request{HostMatcher: "^c$"},
+		Response: Response{StatusCode: 200}}}
+	if errs := config.Validate(); len(errs) != 1 || !strings.Contains(errs[0].Error(), "host-matcher") {
+		t.Errorf("Validate() %v, expected the host-matcher rejected", errs)
+	}
+}
+
+// dialServerName connects to addr asking for serverName (SNI), and returns the
+// DNS names of the certificate served, and whether a client certificate was
+// requested.
+func dialServerName(t *testing.T, addr string, serverName string) ([]string, bool) {
+	t.Helper()
+	requested := false
+	conn, err := tls.Dial("tcp", addr, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			requested = true
+			return &tls.Certificate{}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Dial() %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	return conn.ConnectionState().PeerCertificates[0].DNSNames, requested
+}
+
+func TestServerConfig_SNI(t *testing.T) {
+	config := &Configuration{
+		TLS: &TLSConfiguration{SelfSigned: []string{"service.example.com"}},
+		VirtualHosts: []VirtualHost{
+			{Host: `^router1\.example\.com$`},
+			{Host: `^router2\.example\.com$`, TLS: &TLSConfiguration{SelfSigned: []string{"router2.example.com"}, ClientAuth: ClientAuthRequest}},
+		},
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() %v", err)
+	}
+	server := &http.Server{Handler: http.NotFoundHandler(), ErrorLog: log.New(ioutil.Discard, "", 0)}
+	if err := config.ConfigureTLS(server); err != nil {
+		t.Fatalf("ConfigureTLS() %v", err)
+	}
+	go func() { _ = server.ServeTLS(l, "", "") }()
+	defer func() { _ = server.Close() }()
+
+	tests := []struct {
+		serverName string
+		want       []string
+		requested  bool
+	}{
+		{"router2.example.com", []string{"router2.example.com"}, true},
+		{"ROUTER2.example.com", []string{"router2.example.com"}, true},
+		{"router1.example.com", []string{"service.example.com"}, false},
+		{"", []string{"service.example.com"}, false},
+	}
+	for _, tt := range tests {
+		names, requested := dialServerName(t, l.Addr().String(), tt.serverName)
+		if !reflect.DeepEqual(names, tt.want) || requested != tt.requested {
+			t.Errorf("server name '%s' served %v, client certificate requested %t, expected %v, %t",
+				tt.serverName, names, requested, tt.want, tt.requested)
+		}
+	}
+}
+
+// leafNames returns the DNS names of the certificate of config.
+func leafNames(t *testing.T, config *tls.Config) []string {
+	t.Helper()
+	leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate() %v", err)
+	}
+	return leaf.DNSNames
+}
+
+func TestServerConfig_NoVirtualHostTLS(t *testing.T) {
+	config := &Configuration{
+		TLS:          &TLSConfiguration{SelfSigned: []string{"service.example.com"}},
+		VirtualHosts: []VirtualHost{{Host: `^router1\.example\.com$`}},
+	}
+	c, err := config.ServerConfig()
+	if err != nil {
+		t.Fatalf("ServerConfig() %v", err)
+	}
+	if c.GetConfigForClient != nil {
+		t.Errorf("GetConfigForClient set, expected the configuration of the service only")
+	}
+	if got := leafNames(t, c); !reflect.DeepEqual(got, []string{"service.example.com"}) {
+		t.Errorf("certificate names %v, expected service.example.com", got)
+	}
+}
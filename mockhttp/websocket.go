@@ -0,0 +1,215 @@
+package mockhttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/thorsager/mockdev/websocket"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const wsMessage = "message"
+
+// WebSocket makes a conversation upgrade matching requests to WebSocket, and
+// then run a message-level conversation: each message received is matched
+// against Messages, in order, and the reply of the first match is sent. Push
+// messages are sent by the server on its own, while the connection is open.
+type WebSocket struct {
+	Subprotocols []string           `yaml:"subprotocols,omitempty"` // first offered by the client is selected
+	Messages     []WebSocketMessage `yaml:"messages"`
+	Push         []WebSocketPush    `yaml:"push,omitempty"`
+	NoMatch      string             `yaml:"no-match,omitempty"` // reply to messages not matched, none if empty
+}
+
+// WebSocketMessage replies to received messages matching Matcher, match-groups
+// are available to the reply template as m0..mN, and the message as '.message'.
+type WebSocketMessage struct {
+	Name    string        `yaml:"name"`
+	Matcher string        `yaml:"matcher"`
+	Reply   string        `yaml:"reply"`
+	Binary  bool          `yaml:"binary,omitempty"` // reply is sent as a binary message
+	Delay   ResponseDelay `yaml:"delay,omitempty"`  // before the reply is sent
+	Close   bool          `yaml:"close,omitempty"`  // close the connection after replying
+}
+
+// WebSocketPush is a message sent by the server after Delay, and again every
+// Interval, if set, Repeat times or until the connection is closed if Repeat is 0.
+// The message is thus sent Repeat+1 times in all.
+type WebSocketPush struct {
+	Body     string        `yaml:"body"`
+	Binary   bool          `yaml:"binary,omitempty"`
+	Delay    ResponseDelay `yaml:"delay,omitempty"`
+	Interval Duration      `yaml:"interval,omitempty"`
+	Repeat   int           `yaml:"repeat,omitempty"`
+}
+
+func messageType(binary bool) int {
+	if binary {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
+}
+
+// serveWebSocket upgrades r to WebSocket, and runs the message conversation of
+// conversation until the connection is closed.
+func (h *ConversationsHandler) serveWebSocket(ctx context.Context, w http.ResponseWriter, r *http.Request, conversation compiledConversation) error {
+	ws := *conversation.WebSocket
+	templateVars, err := h.createTemplateData(r, conversation)
+	if err != nil {
+		return err
+	}
+	for _, s := range conversation.Response.Headers {
+		buf := &bytes.Buffer{}
+		tmpl, err := h.template("header", s)
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(buf, templateVars); err != nil {
+			return err
+		}
+		addHeaderFromString(w, buf.String())
+	}
+	w.Header().Add("X-Powered-By", "mockdev")
+
+	conn, err := websocket.Upgrade(w, r, ws.Subprotocols)
+	if err != nil {
+		return err
+	}
+	h.Log.Infof("Upgraded to websocket by conversation: '%d:%s' (%s)", conversation.Order, conversation.Name, r.URL)
+
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for _, p := range ws.Push {
+		wg.Add(1)
+		go func(p WebSocketPush) {
+			defer wg.Done()
+			h.pushWebSocket(conn, p, templateVars, done)
+		}(p)
+	}
+
+	err = h.converseWebSocket(ctx, conn, ws, conversation.wsMatcher, templateVars)
+	close(done)
+	_ = conn.Close(websocket.CloseNormal, "")
+	wg.Wait()
+	h.Log.Infof("Websocket closed, conversation: '%d:%s' (%s)", conversation.Order, conversation.Name, r.URL)
+
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return nil
+	}
+	return err
+}
+
+// converseWebSocket replies to messages received on conn, until it is closed, or
+// a message with close is matched. matchers are the compiled matchers of the
+// messages of ws.
+func (h *ConversationsHandler) converseWebSocket(ctx context.Context, conn *websocket.Conn, ws WebSocket, matchers []*regexp.Regexp, baseVars map[string]interface{}) error {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		h.Log.Debugf("websocket received: %s", data)
+		_ = h.logRequestBody(ctx, bytes.NewBuffer(append(data, '\n')))
+
+		templateVars := copyTemplateData(baseVars)
+		templateVars[wsMessage] = string(data)
+
+		var matched *WebSocketMessage
+		for i, m := range matchers {
+			groups := m.FindStringSubmatch(string(data))
+			if groups == nil {
+				continue
+			}
+			for j, g := range groups {
+				templateVars[fmt.Sprintf("m%d", j)] = g
+			}
+			matched = &ws.Messages[i]
+			break
+		}
+
+		if matched == nil {
+			h.Log.Warnf("No websocket message matched: %s", data)
+			if ws.NoMatch != "" {
+				if err := h.sendWebSocket(conn, websocket.TextMessage, ws.NoMatch, templateVars); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		h.Log.Debugf("matched websocket message: %s", matched.Name)
+		if err := h.handleDelay(matched.Delay); err != nil {
+			h.Log.Errorf("While handling websocket delay: %v", err)
+		}
+		if matched.Reply != "" {
+			if err := h.sendWebSocket(conn, messageType(matched.Binary), matched.Reply, templateVars); err != nil {
+				return err
+			}
+		}
+		if matched.Close {
+			h.Log.Info("websocket closed by conversation.")
+			return nil
+		}
+	}
+}
+
+// pushWebSocket sends the push message p on conn, until done is closed.
+func (h *ConversationsHandler) pushWebSocket(conn *websocket.Conn, p WebSocketPush, templateVars map[string]interface{}, done chan struct{}) {
+	if !p.Delay.IsZero() {
+		d, err := p.Delay.Sample(h.randomSource())
+		if err != nil {
+			h.Log.Errorf("While handling websocket push delay: %v", err)
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Duration(float64(d) * h.latencyMultiplier())):
+		}
+	}
+	for i := 0; ; i++ {
+		if err := h.sendWebSocket(conn, messageType(p.Binary), p.Body, copyTemplateData(templateVars)); err != nil {
+			h.Log.Debugf("websocket push stopped: %v", err)
+			return
+		}
+		if p.Interval <= 0 || (p.Repeat > 0 && i >= p.Repeat) {
+			return
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Duration(p.Interval)):
+		}
+	}
+}
+
+func (h *ConversationsHandler) sendWebSocket(conn *websocket.Conn, messageType int, text string, templateVars map[string]interface{}) error {
+	tmpl, err := h.template("websocket", text)
+	if err != nil {
+		return err
+	}
+	buf := &strings.Builder{}
+	if err := tmpl.Execute(buf, templateVars); err != nil {
+		return err
+	}
+	h.Log.Tracef("websocket sent: %s", buf.String())
+	return conn.WriteMessage(messageType, []byte(buf.String()))
+}
+
+// copyTemplateData returns a copy of templateVars, with the current time updated,
+// as the data of a websocket lives as long as the connection.
+func copyTemplateData(templateVars map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(templateVars)+2)
+	for k, v := range templateVars {
+		c[k] = v
+	}
+	now := time.Now()
+	loc, _ := time.LoadLocation("GMT")
+	c[currentTime] = now
+	c[currentTimeGMT] = now.In(loc)
+	return c
+}
@@ -0,0 +1,95 @@
+package mockhttp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebSocketOverTLS(t *testing.T) {
+	h := newTestHandler(Conversation{
+		Name:    "ws",
+		Request: Request{UrlMatcher: UrlMatcher{Path: "^/ws$"}},
+		WebSocket: &WebSocket{Messages: []WebSocketMessage{
+			{Name: "ping", Matcher: `^ping (\d+)$`, Reply: "pong {{ .m1 }}"},
+		}},
+	})
+	url := startTLSServer(t, h)
+
+	conn, err := tls.Dial("tcp", strings.TrimPrefix(url, "https://"), &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"h2", "http/1.1"},
+	})
+	if err != nil {
+		t.Fatalf("Dial() %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if p := conn.ConnectionState().NegotiatedProtocol; p == "h2" {
+		t.Fatalf("negotiated %s", p)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, url+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("Write() %v", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("ReadResponse() %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+
+	if err := writeClientFrame(conn, 1, []byte("ping 42")); err != nil {
+		t.Fatalf("writeClientFrame() %v", err)
+	}
+	opcode, payload, err := readServerFrame(br)
+	if err != nil {
+		t.Fatalf("readServerFrame() %v", err)
+	}
+	if opcode != 1 || string(payload) != "pong 42" {
+		t.Errorf("got opcode %d %q, want 1 %q", opcode, payload, "pong 42")
+	}
+}
+
+// writeClientFrame writes a single masked frame, as sent by a client.
+func writeClientFrame(w io.Writer, opcode int, payload []byte) error {
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | byte(opcode), 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+// readServerFrame reads a single unmasked frame, as sent by the server.
+func readServerFrame(r io.Reader) (int, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	length := int(head[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, err := io.ReadFull(r, payload)
+	return int(head[0] & 0x0f), payload, err
+}
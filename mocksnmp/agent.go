@@ -0,0 +1,229 @@
+package mocksnmp
+
+import (
+	"errors"
+	"github.com/slayercat/GoSNMPServer"
+	"github.com/thorsager/mockdev/logging"
+	"github.com/thorsager/mockdev/snmpsup"
+	"net"
+	"sort"
+	"sync"
+)
+
+// Agent serves the OIDs of a Configuration over SNMP, unlike a plain
+// GoSNMPServer.SNMPServer the OIDs served may be changed while the Agent is
+// serving.
+type Agent struct {
+	sync.RWMutex
+	Log            logging.Logger
+	ReadCommunity  string
+	WriteCommunity string
+	pdus           pduMap
+	master         *GoSNMPServer.MasterAgent
+}
+
+func NewAgent(config *Configuration, logger logging.Logger) (*Agent, error) {
+	pdus, err := parseConfigOIDs(config)
+	if err != nil {
+		return nil, err
+	}
+	a := &Agent{
+		Log:            logger,
+		ReadCommunity:  config.ReadCommunity,
+		WriteCommunity: config.WriteCommunity,
+	}
+	if err := a.setPDUs(pdus); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// OIDs returns all OIDs served by the agent, in the snmp-snapshot format.
+func (a *Agent) OIDs() []string {
+	a.RLock()
+	defer a.RUnlock()
+	var oids []string
+	for _, pdu := range a.pdus {
+		oids = append(oids, pdu.String())
+	}
+	sort.Strings(oids)
+	return oids
+}
+
+// GetOID returns the snmp-snapshot representation of oid, if served.
+func (a *Agent) GetOID(oid string) (string, bool) {
+	a.RLock()
+	defer a.RUnlock()
+	if pdu, found := a.pdus[oid]; found {
+		return pdu.String(), true
+	}
+	return "", false
+}
+
+// PutOIDs adds the OIDs in the snmp-snapshot format to the agent, replacing any
+// OIDs already served.
+func (a *Agent) PutOIDs(snapshotStrings ...string) error {
+	var added []*snmpsup.NeutralPDU
+	for _, s := range snapshotStrings {
+		npdu, err := snmpsup.ParseNeutralPDU(s)
+		if err != nil {
+			return err
+		}
+		added = append(added, npdu)
+	}
+	a.Lock()
+	defer a.Unlock()
+	pdus := a.pdus.clone()
+	for _, npdu := range added {
+		pdus[npdu.Oid] = npdu
+	}
+	return a.setPDUs(pdus)
+}
+
+// SetOIDs replaces all OIDs served by the agent with the OIDs passed in the
+// snmp-snapshot format.
+func (a *Agent) SetOIDs(snapshotStrings ...string) error {
+	prepared, err := a.PrepareOIDs(snapshotStrings...)
+	if err != nil {
+		return err
+	}
+	a.ServeOIDs(prepared)
+	return nil
+}
+
+// PreparedOIDs are OIDs ready to be served by the Agent that prepared them.
+type PreparedOIDs struct {
+	pdus   pduMap
+	master *GoSNMPServer.MasterAgent
+}
+
+// PrepareOIDs parses the OIDs passed in the snmp-snapshot format, and prepares
+// them to replace all OIDs served by ServeOIDs, which cannot fail. Nothing
+// served by the agent is changed.
+func (a *Agent) PrepareOIDs(snapshotStrings ...string) (*PreparedOIDs, error) {
+	var pdus []*snmpsup.NeutralPDU
+	for _, s := range snapshotStrings {
+		npdu, err := snmpsup.ParseNeutralPDU(s)
+		if err != nil {
+			return nil, err
+		}
+		pdus = append(pdus, npdu)
+	}
+	return a.preparePDUs(uniqueLast(pdus))
+}
+
+// ServeOIDs replaces all OIDs served by the agent with the prepared OIDs.
+func (a *Agent) ServeOIDs(prepared *PreparedOIDs) {
+	a.Lock()
+	defer a.Unlock()
+	a.pdus = prepared.pdus
+	a.master = prepared.master
+}
+
+// DeleteOID stops the agent from serving oid, false is returned if the oid
+// was not served.
+func (a *Agent) DeleteOID(oid string) (bool, error) {
+	a.Lock()
+	defer a.Unlock()
+	if _, found := a.pdus[oid]; !found {
+		return false, nil
+	}
+	pdus := a.pdus.clone()
+	delete(pdus, oid)
+	return true, a.setPDUs(pdus)
+}
+
+// setPDUs must be called holding the lock (or before the agent is serving)
+func (a *Agent) setPDUs(pdus pduMap) error {
+	prepared, err := a.preparePDUs(pdus)
+	if err != nil {
+		return err
+	}
+	a.pdus = prepared.pdus
+	a.master = prepared.master
+	return nil
+}
+
+func (a *Agent) preparePDUs(pdus pduMap) (*PreparedOIDs, error) {
+	var cis []*GoSNMPServer.PDUValueControlItem
+	for _, npdu := range pdus {
+		ci, err := valueControlItem(npdu)
+		if err != nil {
+			return nil, err
+		}
+		cis = append(cis, ci)
+	}
+	master := &GoSNMPServer.MasterAgent{
+		Logger: a.Log,
+		SecurityConfig: GoSNMPServer.SecurityConfig{
+			AuthoritativeEngineBoots: 1,
+		},
+		SubAgents: []*GoSNMPServer.SubAgent{
+			{
+				CommunityIDs: []string{a.ReadCommunity, a.WriteCommunity},
+				OIDs:         cis,
+			},
+		},
+	}
+	if err := master.ReadyForWork(); err != nil {
+		return nil, err
+	}
+	return &PreparedOIDs{pdus: pdus, master: master}, nil
+}
+
+func (a *Agent) currentMaster() *GoSNMPServer.MasterAgent {
+	a.RLock()
+	defer a.RUnlock()
+	return a.master
+}
+
+// ListenAndServe listens on the UDP address addr, and serves SNMP requests
+// until the listener fails.
+func (a *Agent) ListenAndServe(addr string) error {
+	listener, err := GoSNMPServer.NewUDPListener("udp", addr)
+	if err != nil {
+		return err
+	}
+	listener.SetupLogger(a.Log)
+	defer listener.Shutdown()
+	for {
+		err := a.serveNext(listener)
+		if err != nil {
+			var opError *net.OpError
+			if errors.As(err, &opError) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (a *Agent) serveNext(listener GoSNMPServer.ISnmpServerListener) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			a.Log.Errorf("while serving request: %v", r)
+		}
+	}()
+	request, replyer, err := listener.NextSnmp()
+	if err != nil {
+		return err
+	}
+	result, err := a.currentMaster().ResponseForBuffer(request)
+	if err != nil {
+		a.Log.Warnf("while creating response: %v", err)
+	}
+	if len(result) != 0 {
+		if err := replyer.ReplyPDU(result); err != nil {
+			a.Log.Errorf("while replying: %v", err)
+		}
+	}
+	return nil
+}
+
+func (m pduMap) clone() pduMap {
+	c := make(pduMap, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -8,3 +8,18 @@ type Configuration struct {
 	ReadCommunity  string   `yaml:"community-ro"`
 	WriteCommunity string   `yaml:"community-rw"`
 }
+
+// LoadOIDs returns the OIDs of the configuration, and the OIDs found in its
+// snapshot-files, in the snmp-snapshot format. Later definitions of an OID
+// override any earlier.
+func (c *Configuration) LoadOIDs() ([]string, error) {
+	pdus, err := parseConfigOIDs(c)
+	if err != nil {
+		return nil, err
+	}
+	var oids []string
+	for _, pdu := range pdus {
+		oids = append(oids, pdu.String())
+	}
+	return oids, nil
+}
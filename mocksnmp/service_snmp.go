@@ -4,66 +4,41 @@ import (
 	"fmt"
 	"github.com/slayercat/GoSNMPServer"
 	"github.com/slayercat/gosnmp"
-	"github.com/thorsager/mockdev/logging"
 	"github.com/thorsager/mockdev/snmpsup"
 	"io/ioutil"
 	"net"
 	"strings"
 )
 
-func parseConfigOIDs(config *Configuration) ([]*GoSNMPServer.PDUValueControlItem, error) {
-	var controlItems []*GoSNMPServer.PDUValueControlItem
-	if cs, err := parseWalkFiles(config.SnapshotFiles); err == nil {
-		controlItems = append(controlItems, cs...)
-	} else {
+func parseConfigOIDs(config *Configuration) (pduMap, error) {
+	pdus, err := parseWalkFiles(config.SnapshotFiles)
+	if err != nil {
 		return nil, err
 	}
 
 	for _, str := range config.OIDs {
-		ci, err := ControlItemFromString(str)
+		npdu, err := snmpsup.ParseNeutralPDU(str)
 		if err != nil {
 			return nil, err
 		}
-		controlItems = append(controlItems, ci)
+		pdus = append(pdus, npdu)
 	}
 
-	return uniqueLast(controlItems), nil
+	return uniqueLast(pdus), nil
 }
 
-func uniqueLast(items []*GoSNMPServer.PDUValueControlItem) []*GoSNMPServer.PDUValueControlItem {
-	buf := make(map[string]*GoSNMPServer.PDUValueControlItem)
-	for _, item := range items {
-		buf[item.OID] = item
-	}
-	var finalBuf []*GoSNMPServer.PDUValueControlItem
-	for _, v := range buf {
-		finalBuf = append(finalBuf, v)
-	}
-	return finalBuf
-}
+type pduMap map[string]*snmpsup.NeutralPDU
 
-func NewServer(config *Configuration, logger logging.Logger) (*GoSNMPServer.SNMPServer, error) {
-	cis, err := parseConfigOIDs(config)
-	if err != nil {
-		return nil, err
-	}
-	master := GoSNMPServer.MasterAgent{
-		Logger: logger,
-		SecurityConfig: GoSNMPServer.SecurityConfig{
-			AuthoritativeEngineBoots: 1,
-		},
-		SubAgents: []*GoSNMPServer.SubAgent{
-			{
-				CommunityIDs: []string{config.ReadCommunity, config.WriteCommunity},
-				OIDs:         cis,
-			},
-		},
+func uniqueLast(pdus []*snmpsup.NeutralPDU) pduMap {
+	buf := make(pduMap)
+	for _, pdu := range pdus {
+		buf[pdu.Oid] = pdu
 	}
-	return GoSNMPServer.NewSNMPServer(master), nil
+	return buf
 }
 
-func parseWalkFiles(files []string) ([]*GoSNMPServer.PDUValueControlItem, error) {
-	var oids []*GoSNMPServer.PDUValueControlItem
+func parseWalkFiles(files []string) ([]*snmpsup.NeutralPDU, error) {
+	var oids []*snmpsup.NeutralPDU
 	for _, file := range files {
 		os, err := parseSnapshotFile(file)
 		if err != nil {
@@ -74,21 +49,21 @@ func parseWalkFiles(files []string) ([]*GoSNMPServer.PDUValueControlItem, error)
 	return oids, nil
 }
 
-func parseSnapshotFile(file string) ([]*GoSNMPServer.PDUValueControlItem, error) {
+func parseSnapshotFile(file string) ([]*snmpsup.NeutralPDU, error) {
 	data, err := ioutil.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
-	var oids []*GoSNMPServer.PDUValueControlItem
+	var oids []*snmpsup.NeutralPDU
 	for _, line := range strings.Split(string(data), "\n") {
 		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
 			continue
 		}
-		ci, err := ControlItemFromString(line)
+		npdu, err := snmpsup.ParseNeutralPDU(line)
 		if err != nil {
 			return nil, err
 		}
-		oids = append(oids, ci)
+		oids = append(oids, npdu)
 	}
 	return oids, nil
 }
@@ -0,0 +1,41 @@
+package mocksnmp
+
+import (
+	"fmt"
+	"github.com/thorsager/mockdev/snmpsup"
+	"github.com/thorsager/mockdev/validation"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+// Validate checks the configuration, including all lines of its snapshot-files,
+// for OIDs that can not be parsed. Errors found in snapshot-files are located in
+// those files.
+func (c *Configuration) Validate() validation.Errors {
+	var errs validation.Errors
+	if _, _, err := net.SplitHostPort(c.BindAddr); err != nil {
+		errs.Add("bind-addr", c.BindAddr, err)
+	}
+	for _, file := range c.SnapshotFiles {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			errs.AddAt(file, 0, "", err)
+			continue
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+				continue
+			}
+			if _, err := snmpsup.ParseNeutralPDU(line); err != nil {
+				errs.AddAt(file, i+1, "", err)
+			}
+		}
+	}
+	for i, oid := range c.OIDs {
+		if _, err := snmpsup.ParseNeutralPDU(oid); err != nil {
+			errs.Add(fmt.Sprintf("oids[%d]", i), oid, err)
+		}
+	}
+	return errs
+}
@@ -46,6 +46,21 @@ type Response struct {
 	TerminateConnection bool   `yaml:"terminate-connection"`
 }
 
+// LoadConversations returns the conversations of the configuration, and the
+// conversations found in its conversation-files, sorted by match-order.
+func (c *Configuration) LoadConversations() ([]Conversation, error) {
+	conversations := append([]Conversation(nil), c.Conversations...)
+	for _, cf := range c.ConversationFiles {
+		con, err := DecodeConversationFile(cf)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, con...)
+	}
+	SortConversations(conversations)
+	return conversations, nil
+}
+
 func DecodeConversationFile(filename string) ([]Conversation, error) {
 	cff, err := os.Open(filename)
 	if err != nil {
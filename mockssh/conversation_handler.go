@@ -6,9 +6,11 @@ import (
 	"github.com/gliderlabs/ssh"
 	"github.com/sirupsen/logrus"
 	"github.com/thorsager/mockdev/logging"
+	"io/ioutil"
 	"os"
 	"path"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -16,7 +18,9 @@ import (
 
 type Handler struct {
 	sync.Mutex
-	Conversations      []Conversation
+	Conversations      []Conversation // served, loaded and added merged by match-order
+	loaded             []Conversation // loaded from the configuration
+	added              []Conversation // added at runtime
 	Log                logging.Logger
 	Users              map[string]Credentials
 	DefaultPrompt      string
@@ -39,6 +43,38 @@ func (h *Handler) nextSession() int {
 	return h.sessionCounter
 }
 
+// ResetSessions will reset the session counter, making the next session id 1.
+func (h *Handler) ResetSessions() {
+	h.Lock()
+	defer h.Unlock()
+	h.sessionCounter = 0
+}
+
+// SessionLogs returns the ids of all sessions found in the session-log location.
+func (h *Handler) SessionLogs() ([]int, error) {
+	entries, err := ioutil.ReadDir(h.SessionLogLocation)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ids []int
+	for _, e := range entries {
+		var id int
+		if _, err := fmt.Sscanf(e.Name(), "sess_%d.log", &id); err == nil && !e.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// ReadSessionLog returns the content of the session-log of session sesId.
+func (h *Handler) ReadSessionLog(sesId int) ([]byte, error) {
+	return ioutil.ReadFile(h.sessionFilename(sesId))
+}
+
 func (h *Handler) sessionFilename(sesId int) string {
 	return path.Join(h.SessionLogLocation, fmt.Sprintf("sess_%d.log", sesId))
 }
@@ -186,7 +222,7 @@ func (h *Handler) handle(s ssh.Session) {
 
 func (h *Handler) findConversation(line string) *Conversation {
 	convkey := strings.TrimSpace(line)
-	for _, conv := range h.Conversations {
+	for _, conv := range h.GetConversations() {
 		matcher := regexp.MustCompile(conv.RequestMatcher)
 		if matcher.MatchString(convkey) {
 			h.Log.Debugf("matched conv: %s", conv.Name)
@@ -0,0 +1,104 @@
+package mockssh
+
+import (
+	"sort"
+)
+
+// GetConversations returns a copy of the conversations currently served by the
+// handler, ordered by match-order.
+func (h *Handler) GetConversations() []Conversation {
+	h.Lock()
+	defer h.Unlock()
+	return append([]Conversation(nil), h.Conversations...)
+}
+
+// GetConversation returns the conversation with the passed name, if found.
+func (h *Handler) GetConversation(name string) (Conversation, bool) {
+	return lookupByName(h.GetConversations(), name)
+}
+
+// SetConversations replaces all conversations served by the handler, they are
+// kept as added at runtime, see SetLoadedConversations.
+func (h *Handler) SetConversations(conversations []Conversation) {
+	h.Lock()
+	defer h.Unlock()
+	h.loaded = nil
+	h.added = append([]Conversation(nil), conversations...)
+	h.mergeConversations()
+}
+
+// SetLoadedConversations replaces the conversations loaded from the
+// configuration, conversations added at runtime by SetConversations and
+// PutConversation are kept, and replace loaded conversations of the same name.
+func (h *Handler) SetLoadedConversations(conversations []Conversation) {
+	h.Lock()
+	defer h.Unlock()
+	h.loaded = append([]Conversation(nil), conversations...)
+	h.mergeConversations()
+}
+
+// PutConversation adds a conversation to the handler, any conversation with the
+// same name is replaced. The returned bool is true if a conversation was replaced.
+func (h *Handler) PutConversation(conversation Conversation) bool {
+	h.Lock()
+	defer h.Unlock()
+	_, replaced := lookupByName(h.Conversations, conversation.Name)
+	h.added = append(removeByName(h.added, conversation.Name), conversation)
+	h.mergeConversations()
+	return replaced
+}
+
+// DeleteConversation removes the conversation with the passed name, it returns
+// false if no such conversation was found. A deleted conversation, that was
+// loaded from the configuration, is served again when the configuration is
+// reloaded.
+func (h *Handler) DeleteConversation(name string) bool {
+	h.Lock()
+	defer h.Unlock()
+	_, deleted := lookupByName(h.Conversations, name)
+	h.loaded = removeByName(h.loaded, name)
+	h.added = removeByName(h.added, name)
+	h.mergeConversations()
+	return deleted
+}
+
+// mergeConversations sets the conversations served to the loaded conversations,
+// and those added at runtime, by match-order. It must be called holding the lock.
+func (h *Handler) mergeConversations() {
+	conversations := make([]Conversation, 0, len(h.loaded)+len(h.added))
+	for _, c := range h.loaded {
+		if _, found := lookupByName(h.added, c.Name); !found {
+			conversations = append(conversations, c)
+		}
+	}
+	conversations = append(conversations, h.added...)
+	SortConversations(conversations)
+	h.Conversations = conversations
+}
+
+// lookupByName returns the conversation named name, if found.
+func lookupByName(conversations []Conversation, name string) (Conversation, bool) {
+	for _, c := range conversations {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Conversation{}, false
+}
+
+// removeByName returns conversations without the conversation named name.
+func removeByName(conversations []Conversation, name string) []Conversation {
+	kept := make([]Conversation, 0, len(conversations))
+	for _, c := range conversations {
+		if c.Name != name {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// SortConversations sorts conversations by match-order, keeping the original
+// order of conversations with equal match-order.
+func SortConversations(conversations []Conversation) {
+	sort.SliceStable(conversations, func(i, j int) bool { return conversations[i].Order < conversations[j].Order })
+}
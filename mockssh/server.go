@@ -3,27 +3,18 @@ package mockssh
 import (
 	"github.com/gliderlabs/ssh"
 	"github.com/thorsager/mockdev/logging"
-	"sort"
 )
 
-func NewServer(config *Configuration, logger logging.Logger) (*ssh.Server, error) {
-	conversations := config.Conversations
-
-	for _, cf := range config.ConversationFiles {
-		// this is where we load the reset of the conversations
-		con, err := DecodeConversationFile(cf)
-		if err != nil {
-			logger.Error(err)
-		} else {
-			conversations = append(conversations, con...)
-		}
+func NewHandler(config *Configuration, logger logging.Logger) (*Handler, error) {
+	conversations, err := config.LoadConversations()
+	if err != nil {
+		return nil, err
 	}
-	sort.Slice(conversations, func(i, j int) bool { return conversations[i].Order < conversations[j].Order })
 	for _, c := range conversations {
 		logger.Infof("loaded conversation[%d]: %s", c.Order, c.Name)
 	}
 
-	handler := Handler{Conversations: conversations,
+	h := &Handler{
 		Log:                logger,
 		Users:              config.Users,
 		DefaultPrompt:      config.DefaultPrompt,
@@ -32,6 +23,11 @@ func NewServer(config *Configuration, logger logging.Logger) (*ssh.Server, error
 		SessionLogSent:     config.Logging.LogSent,
 		SessionLogReceived: config.Logging.LogReceived,
 	}
+	h.SetLoadedConversations(conversations)
+	return h, nil
+}
+
+func NewServer(config *Configuration, handler *Handler, logger logging.Logger) (*ssh.Server, error) {
 	s := &ssh.Server{
 		Addr:             config.BindAddr,
 		Handler:          handler.handle,
@@ -0,0 +1,83 @@
+package mockssh
+
+import (
+	"fmt"
+	"github.com/gliderlabs/ssh"
+	"github.com/thorsager/mockdev/validation"
+	"io/ioutil"
+	"net"
+	"os"
+	"regexp"
+)
+
+// Validate checks the configuration, and all conversations in it and in its
+// conversation-files, for errors that would otherwise first show when serving.
+// Errors found in conversation-files are located in those files.
+func (c *Configuration) Validate() validation.Errors {
+	var errs validation.Errors
+	if _, _, err := net.SplitHostPort(c.BindAddr); err != nil {
+		errs.Add("bind-addr", c.BindAddr, err)
+	}
+	for i, file := range c.HostKeyFiles {
+		if err := (&ssh.Server{}).SetOption(ssh.HostKeyFile(file)); err != nil {
+			errs.Add(fmt.Sprintf("host-key-files[%d]", i), file, err)
+		}
+	}
+	for i, key := range c.HostKeyPEM {
+		if err := (&ssh.Server{}).SetOption(ssh.HostKeyPEM([]byte(key))); err != nil {
+			errs.Add(fmt.Sprintf("host-keys[%d]", i), key, err)
+		}
+	}
+	for user, cred := range c.Users {
+		if cred.AuthorizedKey == "" {
+			continue
+		}
+		if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cred.AuthorizedKey)); err != nil {
+			errs.Add(fmt.Sprintf("users[%s].authorized-key", user), cred.AuthorizedKey, err)
+		}
+	}
+	for i, conv := range c.Conversations {
+		errs = append(errs, conv.Validate().Within(conversationField(i, conv), conv.Name)...)
+	}
+	for _, cf := range c.ConversationFiles {
+		data, err := ioutil.ReadFile(cf)
+		if err != nil {
+			errs.AddAt(cf, 0, "", err)
+			continue
+		}
+		conversations, err := DecodeConversationFile(cf)
+		if err != nil {
+			errs.AddAt(cf, 0, "", err)
+			continue
+		}
+		for i, conv := range conversations {
+			errs = append(errs, conv.Validate().Within(conversationField(i, conv), conv.Name).Locate(cf, data)...)
+		}
+	}
+	return errs
+}
+
+func conversationField(i int, c Conversation) string {
+	if c.Name == "" {
+		return fmt.Sprintf("conversations[%d]", i)
+	}
+	return fmt.Sprintf("conversations[%s]", c.Name)
+}
+
+// Validate checks the conversation for errors that would otherwise first show
+// when it is matched or served.
+func (c Conversation) Validate() validation.Errors {
+	var errs validation.Errors
+	if c.Name == "" {
+		errs.Add("name", "", fmt.Errorf("missing name"))
+	}
+	if _, err := regexp.Compile(c.RequestMatcher); err != nil {
+		errs.Add("request-matcher", c.RequestMatcher, err)
+	}
+	if c.Response.BodyFile != "" {
+		if _, err := os.Stat(c.Response.BodyFile); err != nil {
+			errs.Add("response.body-file", c.Response.BodyFile, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,333 @@
+// Package openapi reads OpenAPI 3 documents, in YAML or JSON, into the
+// operations needed to mock the API they describe.
+package openapi
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Methods are the operation methods of a path item, in the order operations are
+// returned.
+var Methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Document is a parsed OpenAPI document, kept as generic values, so that any
+// '$ref' can be resolved as a JSON pointer.
+type Document struct {
+	root map[string]interface{}
+}
+
+// Operation is a single operation of a document, with the response to mock it.
+type Operation struct {
+	Id          string
+	Summary     string
+	Method      string // upper case
+	Path        string // path template, including the base path of the first server
+	PathParams  map[string]string
+	StatusCode  int
+	ContentType string
+	Headers     map[string]string
+	Body        []byte
+}
+
+// Load parses an OpenAPI 3 document, JSON being a subset of YAML both are
+// supported.
+func Load(data []byte) (*Document, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	root, ok := normalize(raw).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not an OpenAPI document")
+	}
+	version, _ := root["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version '%v', must be 3.x", root["openapi"])
+	}
+	return &Document{root: root}, nil
+}
+
+// IsOpenAPI returns true if data looks like an OpenAPI document, a mapping with
+// the key 'openapi'.
+func IsOpenAPI(data []byte) bool {
+	var probe struct {
+		OpenAPI string `yaml:"openapi"`
+	}
+	return yaml.Unmarshal(data, &probe) == nil && probe.OpenAPI != ""
+}
+
+// normalize converts the maps decoded by yaml into map[string]interface{}, so
+// they can be marshalled as JSON.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[fmt.Sprint(k)] = normalize(v)
+		}
+		return m
+	case []interface{}:
+		for i := range t {
+			t[i] = normalize(t[i])
+		}
+	}
+	return v
+}
+
+// resolve follows v if it is a '$ref' within the document, references to other
+// documents are not supported.
+func (d *Document) resolve(v interface{}) (interface{}, error) {
+	for i := 0; i < 32; i++ {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v, nil
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return v, nil
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil, fmt.Errorf("unsupported $ref '%s', only local references are supported", ref)
+		}
+		var cur interface{} = d.root
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			if un, err := url.PathUnescape(token); err == nil {
+				token = un
+			}
+			cm, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("unresolved $ref '%s'", ref)
+			}
+			if cur, ok = cm[token]; !ok {
+				return nil, fmt.Errorf("unresolved $ref '%s'", ref)
+			}
+		}
+		v = cur
+	}
+	return nil, fmt.Errorf("too many levels of $ref")
+}
+
+func (d *Document) resolveMap(v interface{}) (map[string]interface{}, error) {
+	r, err := d.resolve(v)
+	if err != nil {
+		return nil, err
+	}
+	m, _ := r.(map[string]interface{})
+	return m, nil
+}
+
+// BasePath returns the path of the url of the first server, with any server
+// variables replaced by their default.
+func (d *Document) BasePath() string {
+	servers, _ := d.root["servers"].([]interface{})
+	if len(servers) == 0 {
+		return ""
+	}
+	server, _ := servers[0].(map[string]interface{})
+	raw, _ := server["url"].(string)
+	vars, _ := server["variables"].(map[string]interface{})
+	for name, v := range vars {
+		vm, _ := v.(map[string]interface{})
+		raw = strings.ReplaceAll(raw, "{"+name+"}", fmt.Sprint(vm["default"]))
+	}
+	if u, err := url.Parse(raw); err == nil {
+		raw = u.Path
+	}
+	return strings.TrimSuffix(raw, "/")
+}
+
+// Operations returns all operations of the document, ordered by path and method.
+func (d *Document) Operations() ([]Operation, error) {
+	paths, _ := d.root["paths"].(map[string]interface{})
+	keys := make([]string, 0, len(paths))
+	for k := range paths {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	basePath := d.BasePath()
+
+	var operations []Operation
+	for _, path := range keys {
+		item, err := d.resolveMap(paths[path])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for _, method := range Methods {
+			raw, found := item[method]
+			if !found {
+				continue
+			}
+			op, err := d.operation(basePath+path, method, item, raw)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %v", strings.ToUpper(method), path, err)
+			}
+			operations = append(operations, op)
+		}
+	}
+	return operations, nil
+}
+
+func (d *Document) operation(path string, method string, item map[string]interface{}, raw interface{}) (Operation, error) {
+	spec, err := d.resolveMap(raw)
+	if err != nil {
+		return Operation{}, err
+	}
+	op := Operation{
+		Method:     strings.ToUpper(method),
+		Path:       path,
+		PathParams: make(map[string]string),
+		Headers:    make(map[string]string),
+	}
+	op.Id, _ = spec["operationId"].(string)
+	op.Summary, _ = spec["summary"].(string)
+
+	// path-item parameters, overridden by operation parameters
+	for _, list := range []interface{}{item["parameters"], spec["parameters"]} {
+		params, _ := list.([]interface{})
+		for _, p := range params {
+			param, err := d.resolveMap(p)
+			if err != nil {
+				return Operation{}, err
+			}
+			if in, _ := param["in"].(string); in != "path" {
+				continue
+			}
+			name, _ := param["name"].(string)
+			schema, err := d.resolveMap(param["schema"])
+			if err != nil {
+				return Operation{}, err
+			}
+			op.PathParams[name] = schemaType(schema)
+		}
+	}
+
+	responses, _ := spec["responses"].(map[string]interface{})
+	code, response := pickResponse(responses)
+	op.StatusCode = code
+	if response == nil {
+		return op, nil
+	}
+	resp, err := d.resolveMap(response)
+	if err != nil {
+		return Operation{}, err
+	}
+	headers, _ := resp["headers"].(map[string]interface{})
+	for name, h := range headers {
+		header, err := d.resolveMap(h)
+		if err != nil {
+			return Operation{}, err
+		}
+		value, err := d.example(header)
+		if err != nil {
+			return Operation{}, err
+		}
+		if value != nil {
+			op.Headers[name] = fmt.Sprint(value)
+		}
+	}
+
+	content, _ := resp["content"].(map[string]interface{})
+	contentType := pickContentType(content)
+	if contentType == "" {
+		return op, nil
+	}
+	op.ContentType = contentType
+	media, err := d.resolveMap(content[contentType])
+	if err != nil {
+		return Operation{}, err
+	}
+	value, err := d.example(media)
+	if err != nil {
+		return Operation{}, err
+	}
+	op.Body, err = encode(contentType, value)
+	return op, err
+}
+
+// pickResponse returns the first 2xx response, falling back to 'default' as 200,
+// and then to the first response of any status.
+func pickResponse(responses map[string]interface{}) (int, interface{}) {
+	codes := make([]string, 0, len(responses))
+	for k := range responses {
+		if k != "default" {
+			codes = append(codes, k)
+		}
+	}
+	sort.Strings(codes)
+	for _, k := range codes {
+		if strings.HasPrefix(k, "2") {
+			return statusCode(k), responses[k]
+		}
+	}
+	if r, found := responses["default"]; found {
+		return 200, r
+	}
+	if len(codes) > 0 {
+		return statusCode(codes[0]), responses[codes[0]]
+	}
+	return 200, nil
+}
+
+// statusCode converts a response key to a status code, ranges like '2XX' are
+// converted to the first status of the range.
+func statusCode(key string) int {
+	code, err := strconv.Atoi(strings.ReplaceAll(strings.ToUpper(key), "X", "0"))
+	if err != nil {
+		return 200
+	}
+	return code
+}
+
+// pickContentType prefers JSON, and otherwise the first content-type by name.
+func pickContentType(content map[string]interface{}) string {
+	types := make([]string, 0, len(content))
+	for k := range content {
+		types = append(types, k)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		if isJSON(t) {
+			return t
+		}
+	}
+	if len(types) > 0 {
+		return types[0]
+	}
+	return ""
+}
+
+func isJSON(contentType string) bool {
+	t := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	return t == "application/json" || strings.HasSuffix(t, "+json") || t == "*/*"
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)}`)
+
+// PathRegexp converts a path template, such as '/devices/{id}', to an anchored
+// regular expression. Parameters of type integer only match digits, any other
+// parameter matches a single path segment.
+func PathRegexp(path string, params map[string]string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, loc := range pathParam.FindAllStringSubmatchIndex(path, -1) {
+		b.WriteString(regexp.QuoteMeta(path[last:loc[0]]))
+		switch params[path[loc[2]:loc[3]]] {
+		case "integer":
+			b.WriteString("-?[0-9]+")
+		default:
+			b.WriteString("[^/]+")
+		}
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(path[last:]))
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+const spec = `
+openapi: 3.0.3
+info: {title: test, version: "1"}
+servers:
+  - url: http://{host}:8080/api/
+    variables:
+      host: {default: localhost}
+paths:
+  /ports/{port}:
+    parameters:
+      - $ref: "#/components/parameters/Port"
+    get:
+      operationId: getPort
+      responses:
+        "404": {description: missing}
+        2XX:
+          description: found
+          content:
+            text/plain:
+              example: "port up"
+            application/json:
+              schema: {$ref: "#/components/schemas/Port"}
+  /ports/{port}/name/{name}:
+    put:
+      parameters:
+        - {name: port, in: path, schema: {type: string}}
+        - {name: name, in: path, schema: {type: string}}
+      responses:
+        default:
+          description: any
+          content:
+            application/json:
+              examples:
+                b: {value: {ok: false}}
+                a: {$ref: "#/components/examples/Ok"}
+components:
+  parameters:
+    Port: {name: port, in: path, schema: {type: integer}}
+  examples:
+    Ok: {value: {ok: true}}
+  schemas:
+    Port:
+      allOf:
+        - type: object
+          properties:
+            id: {type: integer, minimum: 1}
+            up: {type: boolean}
+        - properties:
+            tags: {type: array, items: {type: string, enum: [core, lab]}}
+            peer: {$ref: "#/components/schemas/Port"}
+            mtu: {type: [number, "null"], default: 1500}
+`
+
+func TestDocument_Operations(t *testing.T) {
+	doc, err := Load([]byte(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err := doc.Operations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Operation{
+		{
+			Id: "getPort", Method: "GET", Path: "/api/ports/{port}", StatusCode: 200,
+			PathParams: map[string]string{"port": "integer"}, ContentType: "application/json", Headers: map[string]string{},
+			Body: []byte("{\n  \"id\": 1,\n  \"mtu\": 1500,\n  \"peer\": null,\n  \"tags\": [\n    \"core\"\n  ],\n  \"up\": true\n}"),
+		},
+		{
+			Method: "PUT", Path: "/api/ports/{port}/name/{name}", StatusCode: 200,
+			PathParams: map[string]string{"port": "string", "name": "string"}, ContentType: "application/json", Headers: map[string]string{},
+			Body: []byte("{\n  \"ok\": true\n}"),
+		},
+	}
+	if !reflect.DeepEqual(ops, want) {
+		t.Errorf("Operations() =\n%+v\nwant\n%+v", ops, want)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	for _, s := range []string{"swagger: '2.0'", "- a", "openapi: 2.0"} {
+		if _, err := Load([]byte(s)); err == nil {
+			t.Errorf("Load(%q) expected error", s)
+		}
+	}
+}
+
+func TestIsOpenAPI(t *testing.T) {
+	if !IsOpenAPI([]byte(spec)) {
+		t.Errorf("IsOpenAPI() = false, want true")
+	}
+	if IsOpenAPI([]byte("- name: conversation\n")) {
+		t.Errorf("IsOpenAPI() = true on conversation list, want false")
+	}
+}
+
+func TestPathRegexp(t *testing.T) {
+	tests := []struct {
+		path    string
+		params  map[string]string
+		match   []string
+		noMatch []string
+	}{
+		{"/a.b/{id}", map[string]string{"id": "integer"}, []string{"/a.b/12", "/a.b/-1"}, []string{"/axb/12", "/a.b/x", "/a.b/1/2"}},
+		{"/ports/{port}/name", nil, []string{"/ports/ge-0/name"}, []string{"/ports/a/b/name", "/ports//name"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			re := regexp.MustCompile(PathRegexp(tt.path, tt.params))
+			for _, s := range tt.match {
+				if !re.MatchString(s) {
+					t.Errorf("%s did not match %s", re, s)
+				}
+			}
+			for _, s := range tt.noMatch {
+				if re.MatchString(s) {
+					t.Errorf("%s matched %s", re, s)
+				}
+			}
+		})
+	}
+}
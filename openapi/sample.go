@@ -0,0 +1,189 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// stringFormats are the sample values of strings with a known format.
+var stringFormats = map[string]string{
+	"date":      "2006-01-02",
+	"date-time": "2006-01-02T15:04:05Z",
+	"time":      "15:04:05",
+	"email":     "user@example.com",
+	"hostname":  "device.example.com",
+	"ipv4":      "192.0.2.1",
+	"ipv6":      "2001:db8::1",
+	"uri":       "https://example.com/",
+	"url":       "https://example.com/",
+	"uuid":      "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+	"byte":      "ZXhhbXBsZQ==",
+	"password":  "secret",
+}
+
+// example returns the example of a media-type, parameter or header object m,
+// taken from 'example', the first of 'examples', or generated from 'schema'.
+func (d *Document) example(m map[string]interface{}) (interface{}, error) {
+	if v, found := m["example"]; found {
+		return v, nil
+	}
+	if examples, ok := m["examples"].(map[string]interface{}); ok && len(examples) > 0 {
+		names := make([]string, 0, len(examples))
+		for k := range examples {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		ex, err := d.resolveMap(examples[names[0]])
+		if err != nil {
+			return nil, err
+		}
+		if v, found := ex["value"]; found {
+			return v, nil
+		}
+	}
+	if schema, found := m["schema"]; found {
+		return d.Sample(schema)
+	}
+	return nil, nil
+}
+
+// Sample generates a sample value valid for schema, using the examples, defaults
+// and enums of the schema where present.
+func (d *Document) Sample(schema interface{}) (interface{}, error) {
+	return d.sample(schema, map[string]bool{})
+}
+
+func (d *Document) sample(schema interface{}, visiting map[string]bool) (interface{}, error) {
+	m, ok := schema.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	if ref, ok := m["$ref"].(string); ok {
+		if visiting[ref] {
+			return nil, nil // recursive schema, stop here
+		}
+		visiting[ref] = true
+		defer delete(visiting, ref)
+		resolved, err := d.resolve(m)
+		if err != nil {
+			return nil, err
+		}
+		return d.sample(resolved, visiting)
+	}
+	if v, found := m["example"]; found {
+		return v, nil
+	}
+	if examples, ok := m["examples"].([]interface{}); ok && len(examples) > 0 {
+		return examples[0], nil
+	}
+	if v, found := m["default"]; found {
+		return v, nil
+	}
+	if v, found := m["const"]; found {
+		return v, nil
+	}
+	if enum, ok := m["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0], nil
+	}
+	if all, ok := m["allOf"].([]interface{}); ok {
+		merged := make(map[string]interface{})
+		for _, s := range all {
+			v, err := d.sample(s, visiting)
+			if err != nil {
+				return nil, err
+			}
+			if vm, ok := v.(map[string]interface{}); ok {
+				for k, v := range vm {
+					merged[k] = v
+				}
+			} else if v != nil && len(all) == 1 {
+				return v, nil
+			}
+		}
+		return merged, nil
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if list, ok := m[key].([]interface{}); ok && len(list) > 0 {
+			return d.sample(list[0], visiting)
+		}
+	}
+
+	switch schemaType(m) {
+	case "object":
+		obj := make(map[string]interface{})
+		props, _ := m["properties"].(map[string]interface{})
+		for name, p := range props {
+			v, err := d.sample(p, visiting)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			obj[name] = v
+		}
+		return obj, nil
+	case "array":
+		item, err := d.sample(m["items"], visiting)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			return []interface{}{}, nil
+		}
+		return []interface{}{item}, nil
+	case "string":
+		format, _ := m["format"].(string)
+		if s, found := stringFormats[format]; found {
+			return s, nil
+		}
+		return "string", nil
+	case "integer":
+		if v, found := m["minimum"]; found {
+			return v, nil
+		}
+		return 0, nil
+	case "number":
+		if v, found := m["minimum"]; found {
+			return v, nil
+		}
+		return 0.0, nil
+	case "boolean":
+		return true, nil
+	}
+	return nil, nil
+}
+
+// schemaType returns the type of schema, the first type that is not 'null' if
+// multiple are given (OpenAPI 3.1), and 'object' if properties are given
+// without type.
+func schemaType(schema map[string]interface{}) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	if _, found := schema["properties"]; found {
+		return "object"
+	}
+	return ""
+}
+
+// encode returns value as the body of contentType, JSON for JSON content-types,
+// and strings as is for others. Other values can not be encoded for content-types
+// that are not JSON, and are left out.
+func encode(contentType string, value interface{}) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if !isJSON(contentType) {
+		if s, ok := value.(string); ok {
+			return []byte(s), nil
+		}
+		return nil, nil
+	}
+	return json.MarshalIndent(value, "", "  ")
+}
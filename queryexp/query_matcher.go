@@ -61,6 +61,16 @@ func (q *QueryExpr) ContainedInQuery(v url.Values) bool {
 	return q.ContainedInMap(m)
 }
 
+// SubmatchQuery will return the named groups matched in the first value of each
+// parameter of the url.Values, mapped by parameter name and group name.
+func (q *QueryExpr) SubmatchQuery(v url.Values) map[string]map[string]string {
+	m := make(map[string]string)
+	for k, v := range v {
+		m[k] = v[0]
+	}
+	return q.Submatches(m)
+}
+
 // Compile will create a QueryExpr from a string in URL query format, but with
 // the twist that all parameter will be treated as a RegularExpression.
 // ex.
@@ -2,6 +2,7 @@ package queryexp
 
 import (
 	"github.com/thorsager/mockdev/keyvalueexp"
+	"net/url"
 	"reflect"
 	"testing"
 )
@@ -94,3 +95,26 @@ func TestQueryExpr_ContainedInString(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryExpr_SubmatchQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		s    string
+		want map[string]map[string]string
+	}{
+		{"named", "page=^(?P<page>\\d+)$&size=.*", "page=3&size=10", map[string]map[string]string{"page": {"page": "3"}}},
+		{"multi", "range=^(?P<from>\\d+)-(?P<to>\\d+)$", "range=1-5", map[string]map[string]string{"range": {"from": "1", "to": "5"}}},
+		{"no_match", "page=^(?P<page>\\d+)$", "page=x", map[string]map[string]string{}},
+		{"missing", "page=^(?P<page>\\d+)$", "size=10", map[string]map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := MustCompile(tt.expr)
+			u := url.URL{RawQuery: tt.s}
+			if got := q.SubmatchQuery(u.Query()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SubmatchQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"github.com/thorsager/mockdev/mockhttp"
+	"github.com/thorsager/mockdev/mocksnmp"
+	"github.com/thorsager/mockdev/mockssh"
+	"sort"
+	"sync"
+)
+
+// Registry keeps track of the running services by name, so that they can be
+// found and changed at runtime.
+type Registry struct {
+	sync.Mutex
+	http map[string]*mockhttp.ConversationsHandler
+	ssh  map[string]*mockssh.Handler
+	snmp map[string]*mocksnmp.Agent
+}
+
+func New() *Registry {
+	return &Registry{
+		http: make(map[string]*mockhttp.ConversationsHandler),
+		ssh:  make(map[string]*mockssh.Handler),
+		snmp: make(map[string]*mocksnmp.Agent),
+	}
+}
+
+// RegisterHttp registers h as name, it returns false if a service by that name
+// was already registered, in which case it is replaced.
+func (r *Registry) RegisterHttp(name string, h *mockhttp.ConversationsHandler) bool {
+	r.Lock()
+	defer r.Unlock()
+	_, found := r.http[name]
+	r.http[name] = h
+	return !found
+}
+
+// RegisterSsh registers h as name, it returns false if a service by that name
+// was already registered, in which case it is replaced.
+func (r *Registry) RegisterSsh(name string, h *mockssh.Handler) bool {
+	r.Lock()
+	defer r.Unlock()
+	_, found := r.ssh[name]
+	r.ssh[name] = h
+	return !found
+}
+
+// RegisterSnmp registers a as name, it returns false if a service by that name
+// was already registered, in which case it is replaced.
+func (r *Registry) RegisterSnmp(name string, a *mocksnmp.Agent) bool {
+	r.Lock()
+	defer r.Unlock()
+	_, found := r.snmp[name]
+	r.snmp[name] = a
+	return !found
+}
+
+func (r *Registry) Http(name string) (*mockhttp.ConversationsHandler, bool) {
+	r.Lock()
+	defer r.Unlock()
+	h, found := r.http[name]
+	return h, found
+}
+
+func (r *Registry) Ssh(name string) (*mockssh.Handler, bool) {
+	r.Lock()
+	defer r.Unlock()
+	h, found := r.ssh[name]
+	return h, found
+}
+
+func (r *Registry) Snmp(name string) (*mocksnmp.Agent, bool) {
+	r.Lock()
+	defer r.Unlock()
+	a, found := r.snmp[name]
+	return a, found
+}
+
+// HttpNames returns the sorted names of all registered http services.
+func (r *Registry) HttpNames() []string {
+	r.Lock()
+	defer r.Unlock()
+	var names []string
+	for name := range r.http {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SshNames returns the sorted names of all registered ssh services.
+func (r *Registry) SshNames() []string {
+	r.Lock()
+	defer r.Unlock()
+	var names []string
+	for name := range r.ssh {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SnmpNames returns the sorted names of all registered snmp services.
+func (r *Registry) SnmpNames() []string {
+	r.Lock()
+	defer r.Unlock()
+	var names []string
+	for name := range r.snmp {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,45 @@
+package registry
+
+import (
+	"github.com/thorsager/mockdev/mockhttp"
+	"github.com/thorsager/mockdev/mocksnmp"
+	"github.com/thorsager/mockdev/mockssh"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := New()
+	first, second := &mockhttp.ConversationsHandler{}, &mockhttp.ConversationsHandler{}
+	if !r.RegisterHttp("b", first) || !r.RegisterHttp("a", first) {
+		t.Fatalf("RegisterHttp() false, expected true for new names")
+	}
+	if r.RegisterHttp("b", second) {
+		t.Errorf("RegisterHttp() true, expected false for a duplicate name")
+	}
+	if h, found := r.Http("b"); !found || h != second {
+		t.Errorf("Http() %p %t, expected the replacing handler %p", h, found, second)
+	}
+	if _, found := r.Http("c"); found {
+		t.Errorf("Http() found service not registered")
+	}
+	if got, want := r.HttpNames(), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("HttpNames() %v, expected %v", got, want)
+	}
+
+	ssh, agent := &mockssh.Handler{}, &mocksnmp.Agent{}
+	r.RegisterSsh("shell", ssh)
+	r.RegisterSnmp("agent", agent)
+	if h, found := r.Ssh("shell"); !found || h != ssh {
+		t.Errorf("Ssh() %p %t, expected %p", h, found, ssh)
+	}
+	if a, found := r.Snmp("agent"); !found || a != agent {
+		t.Errorf("Snmp() %p %t, expected %p", a, found, agent)
+	}
+	if got, want := r.SshNames(), []string{"shell"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SshNames() %v, expected %v", got, want)
+	}
+	if got, want := r.SnmpNames(), []string{"agent"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SnmpNames() %v, expected %v", got, want)
+	}
+}
@@ -0,0 +1,393 @@
+// Package templatefuncs is the library of functions available to the response
+// templates of mockdev, ex. to generate ids, encode values or do date arithmetic.
+package templatefuncs
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// MaxRandString is the longest string returned by randString.
+const MaxRandString = 64 * 1024
+
+// timeLayouts are the layouts that can be given by name to formatTime and parseTime.
+var timeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"RFC822":      time.RFC822,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"HTTP":        "Mon, 02 Jan 2006 15:04:05 GMT",
+}
+
+// Funcs returns the functions available to templates, arguments are ordered so
+// that functions can be used in pipelines, ex. '{{ now | addDuration "1h" | formatTime "RFC3339" }}'.
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		// ids and random values
+		"uuid":       UUID,
+		"randInt":    randInt,
+		"randString": randString,
+		"randChoice": randChoice,
+
+		// encoding
+		"toJson":       toJson,
+		"toPrettyJson": toPrettyJson,
+		"fromJson":     fromJson,
+		"jsonEscape":   jsonEscape,
+		"b64enc":       b64enc,
+		"b64dec":       b64dec,
+		"urlEncode":    url.QueryEscape,
+		"urlDecode":    url.QueryUnescape,
+
+		// hashing, as lower case hex
+		"md5":    md5sum,
+		"sha1":   sha1sum,
+		"sha256": sha256sum,
+
+		// strings
+		"upper":     strings.ToUpper,
+		"lower":     strings.ToLower,
+		"title":     title,
+		"trim":      strings.TrimSpace,
+		"replace":   replace,
+		"contains":  contains,
+		"hasPrefix": hasPrefix,
+		"hasSuffix": hasSuffix,
+		"split":     split,
+		"join":      join,
+		"default":   defaultValue,
+
+		// numbers
+		"add": add,
+		"sub": sub,
+		"mul": mul,
+		"div": div,
+		"mod": mod,
+
+		// time
+		"now":         time.Now,
+		"addDuration": addDuration,
+		"formatTime":  formatTime,
+		"parseTime":   parseTime,
+		"unix":        unix,
+	}
+}
+
+// UUID returns a random (version 4) UUID.
+func UUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// randInt returns a random integer in [min,max).
+func randInt(min, max interface{}) (int64, error) {
+	lo, err := toInt(min)
+	if err != nil {
+		return 0, err
+	}
+	hi, err := toInt(max)
+	if err != nil {
+		return 0, err
+	}
+	if hi <= lo {
+		return 0, fmt.Errorf("randInt: max %d must be greater than min %d", hi, lo)
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(hi-lo))
+	if err != nil {
+		return 0, err
+	}
+	return lo + n.Int64(), nil
+}
+
+// randString returns a random alphanumeric string of length n, at most
+// MaxRandString.
+func randString(n interface{}) (string, error) {
+	length, err := toInt(n)
+	if err != nil {
+		return "", err
+	}
+	if length < 0 || length > MaxRandString {
+		return "", fmt.Errorf("randString: length %d must be in [0,%d]", length, MaxRandString)
+	}
+	b := make([]byte, length)
+	for i := range b {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphanumeric))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphanumeric[j.Int64()]
+	}
+	return string(b), nil
+}
+
+// randChoice returns one of the choices at random.
+func randChoice(choices ...interface{}) (interface{}, error) {
+	if len(choices) == 0 {
+		return nil, fmt.Errorf("randChoice: no choices")
+	}
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(choices))))
+	if err != nil {
+		return nil, err
+	}
+	return choices[i.Int64()], nil
+}
+
+func toJson(v interface{}) (string, error) {
+	return marshal(v, "")
+}
+
+func toPrettyJson(v interface{}) (string, error) {
+	return marshal(v, "  ")
+}
+
+// marshal encodes v as JSON, without escaping HTML characters, as values are
+// not embedded in HTML.
+func marshal(v interface{}, indent string) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// fromJson decodes s, numbers are kept as json.Number to preserve their
+// representation.
+func fromJson(s string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// jsonEscape returns v as the content of a JSON string, without the quotes, so it
+// can be used within a quoted string of a JSON template.
+func jsonEscape(v interface{}) (string, error) {
+	s, err := marshal(fmt.Sprint(v), "")
+	if err != nil {
+		return "", err
+	}
+	return s[1 : len(s)-1], nil
+}
+
+func b64enc(v interface{}) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(v)))
+}
+
+func b64dec(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	return string(b), err
+}
+
+func md5sum(v interface{}) string {
+	sum := md5.Sum([]byte(fmt.Sprint(v)))
+	return hex.EncodeToString(sum[:])
+}
+
+func sha1sum(v interface{}) string {
+	sum := sha1.Sum([]byte(fmt.Sprint(v)))
+	return hex.EncodeToString(sum[:])
+}
+
+func sha256sum(v interface{}) string {
+	sum := sha256.Sum256([]byte(fmt.Sprint(v)))
+	return hex.EncodeToString(sum[:])
+}
+
+// title returns s with the first letter of each word in upper case.
+func title(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		defer func() { prev = r }()
+		if unicode.IsSpace(prev) || unicode.IsPunct(prev) {
+			return unicode.ToUpper(r)
+		}
+		return r
+	}, s)
+}
+
+func replace(old, new, s string) string {
+	return strings.ReplaceAll(s, old, new)
+}
+
+func contains(substr, s string) bool {
+	return strings.Contains(s, substr)
+}
+
+func hasPrefix(prefix, s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
+
+func hasSuffix(suffix, s string) bool {
+	return strings.HasSuffix(s, suffix)
+}
+
+func split(sep, s string) []string {
+	return strings.Split(s, sep)
+}
+
+// join joins the elements of list, which may be of any slice type, by sep.
+func join(sep string, list interface{}) (string, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: %T is not a list", list)
+	}
+	elems := make([]string, v.Len())
+	for i := range elems {
+		elems[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(elems, sep), nil
+}
+
+// defaultValue returns v, or def if v is empty.
+func defaultValue(def interface{}, v interface{}) interface{} {
+	if v == nil {
+		return def
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if rv.Len() == 0 {
+			return def
+		}
+	}
+	return v
+}
+
+// toInt converts a number, ex. a json.Number, or a string to an integer.
+func toInt(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case int32:
+		return int64(n), nil
+	case uint:
+		return int64(n), nil
+	case uint64:
+		return int64(n), nil
+	case float64:
+		return int64(n), nil
+	case json.Number:
+		return toInt(string(n))
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+		if err != nil {
+			f, ferr := strconv.ParseFloat(strings.TrimSpace(n), 64)
+			if ferr != nil {
+				return 0, fmt.Errorf("'%s' is not a number", n)
+			}
+			return int64(f), nil
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("%v (%T) is not a number", v, v)
+}
+
+func arith(a, b interface{}, op func(x, y int64) (int64, error)) (int64, error) {
+	x, err := toInt(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := toInt(b)
+	if err != nil {
+		return 0, err
+	}
+	return op(x, y)
+}
+
+func add(a, b interface{}) (int64, error) {
+	return arith(a, b, func(x, y int64) (int64, error) { return x + y, nil })
+}
+
+func sub(a, b interface{}) (int64, error) {
+	return arith(a, b, func(x, y int64) (int64, error) { return x - y, nil })
+}
+
+func mul(a, b interface{}) (int64, error) {
+	return arith(a, b, func(x, y int64) (int64, error) { return x * y, nil })
+}
+
+func div(a, b interface{}) (int64, error) {
+	return arith(a, b, func(x, y int64) (int64, error) {
+		if y == 0 {
+			return 0, fmt.Errorf("div: division by zero")
+		}
+		return x / y, nil
+	})
+}
+
+func mod(a, b interface{}) (int64, error) {
+	return arith(a, b, func(x, y int64) (int64, error) {
+		if y == 0 {
+			return 0, fmt.Errorf("mod: division by zero")
+		}
+		return x % y, nil
+	})
+}
+
+// addDuration returns t plus the duration d, ex. "-1h30m".
+func addDuration(d string, t time.Time) (time.Time, error) {
+	duration, err := time.ParseDuration(d)
+	if err != nil {
+		return t, err
+	}
+	return t.Add(duration), nil
+}
+
+// layout returns the layout named name, or name itself if not a known name.
+func layout(name string) string {
+	if l, found := timeLayouts[name]; found {
+		return l
+	}
+	return name
+}
+
+// formatTime formats t by layout, either a layout of package time, ex.
+// "2006-01-02", or the name of one, ex. "RFC3339". The layout "HTTP" formats
+// t in GMT.
+func formatTime(l string, t time.Time) string {
+	if l == "HTTP" {
+		t = t.UTC()
+	}
+	return t.Format(layout(l))
+}
+
+func parseTime(l string, s string) (time.Time, error) {
+	return time.Parse(layout(l), s)
+}
+
+func unix(t time.Time) int64 {
+	return t.Unix()
+}
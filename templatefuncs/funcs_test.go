@@ -0,0 +1,85 @@
+package templatefuncs
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"text/template"
+)
+
+func execute(t *testing.T, text string, data interface{}) string {
+	t.Helper()
+	tmpl, err := template.New("test").Funcs(Funcs()).Parse(text)
+	if err != nil {
+		t.Fatalf("Parse(%q) %v", text, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		t.Fatalf("Execute(%q) %v", text, err)
+	}
+	return buf.String()
+}
+
+func TestFuncs(t *testing.T) {
+	data := map[string]interface{}{
+		"name":  `Joe "the" <Dalton>`,
+		"list":  []string{"a", "b"},
+		"empty": "",
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`{{ toJson .name }}`, `"Joe \"the\" <Dalton>"`},
+		{`"{{ jsonEscape .name }}"`, `"Joe \"the\" <Dalton>"`},
+		{`{{ toJson .list }}`, `["a","b"]`},
+		{`{{ (fromJson "{\"id\": 12}").id }}`, `12`},
+		{`{{ b64enc "mockdev" }} {{ b64dec "bW9ja2Rldg==" }}`, `bW9ja2Rldg== mockdev`},
+		{`{{ urlEncode "a b&c" }}`, `a+b%26c`},
+		{`{{ md5 "mockdev" }}`, `df9cd21a6d12fed74ae05a6bcd923c74`},
+		{`{{ sha256 "" }}`, `e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855`},
+		{`{{ upper "ab" }} {{ lower "AB" }} {{ title "port ge-0/0" }}`, `AB ab Port Ge-0/0`},
+		{`{{ .name | replace "Joe" "Jack" }}`, `Jack "the" <Dalton>`},
+		{`{{ split "," "a,b" | join "-" }} {{ join "+" .list }}`, `a-b a+b`},
+		{`{{ contains "the" .name }} {{ hasPrefix "Jo" .name }} {{ hasSuffix "x" .name }}`, `true true false`},
+		{`{{ .empty | default "none" }} {{ .missing | default "none" }} {{ .name | default "none" }}`, `none none Joe "the" <Dalton>`},
+		{`{{ add 1 2 }} {{ sub "5" 2 }} {{ mul 3 4 }} {{ div 7 2 }} {{ mod 7 2 }}`, `3 3 12 3 1`},
+		{`{{ parseTime "RFC3339" "2020-01-02T03:04:05Z" | addDuration "-1h" | formatTime "2006-01-02 15:04" }}`, `2020-01-02 02:04`},
+		{`{{ parseTime "2006-01-02" "2020-01-02" | formatTime "HTTP" }}`, `Thu, 02 Jan 2020 00:00:00 GMT`},
+		{`{{ parseTime "RFC3339" "1970-01-01T00:01:00Z" | unix }}`, `60`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := execute(t, tt.text, data); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandom(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for i := 0; i < 20; i++ {
+		if got := execute(t, `{{ uuid }}`, nil); !uuid.MatchString(got) {
+			t.Errorf("uuid = %s", got)
+		}
+		if got := execute(t, `{{ randInt 3 5 }}`, nil); got != "3" && got != "4" {
+			t.Errorf("randInt 3 5 = %s", got)
+		}
+		if got := execute(t, `{{ randString 8 }}`, nil); !regexp.MustCompile(`^[a-zA-Z0-9]{8}$`).MatchString(got) {
+			t.Errorf("randString 8 = %s", got)
+		}
+		if got := execute(t, `{{ randChoice "a" "b" }}`, nil); got != "a" && got != "b" {
+			t.Errorf("randChoice = %s", got)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, text := range []string{`{{ div 1 0 }}`, `{{ randInt 5 5 }}`, `{{ randString -1 }}`, `{{ randString 65537 }}`, `{{ add "x" 1 }}`, `{{ fromJson "{" }}`, `{{ join "," 1 }}`} {
+		tmpl := template.Must(template.New("test").Funcs(Funcs()).Parse(text))
+		if err := tmpl.Execute(&bytes.Buffer{}, nil); err == nil {
+			t.Errorf("Execute(%q) expected error", text)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error is a single problem found while validating configuration, File and Line
+// are set when the location of the problem is known.
+type Error struct {
+	File  string
+	Line  int
+	Field string
+	Err   error
+	// anchors are raw configuration values leading up to the error, used to
+	// locate the error in a file, outermost first.
+	anchors []string
+}
+
+func (e *Error) Error() string {
+	var location string
+	if e.File != "" && e.Line > 0 {
+		location = fmt.Sprintf("%s:%d: ", e.File, e.Line)
+	} else if e.File != "" {
+		location = e.File + ": "
+	}
+	if e.Field == "" {
+		return fmt.Sprintf("%s%v", location, e.Err)
+	}
+	return fmt.Sprintf("%s%s: %v", location, e.Field, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors is a list of validation errors, it is itself an error, so that all
+// problems found can be reported at once.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	var lines []string
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Add adds an error for field, value is the raw configuration value of field,
+// and is used to locate the error in the configuration file.
+func (e *Errors) Add(field string, value string, err error) {
+	ve := &Error{Field: field, Err: err}
+	if value != "" {
+		ve.anchors = []string{value}
+	}
+	*e = append(*e, ve)
+}
+
+// AddAt adds an error for field with a known location.
+func (e *Errors) AddAt(file string, line int, field string, err error) {
+	*e = append(*e, &Error{File: file, Line: line, Field: field, Err: err})
+}
+
+// Within places all errors inside field, anchor is the raw configuration value
+// that identifies field (ex. its name) when locating errors, and may be empty.
+func (e Errors) Within(field string, anchor string) Errors {
+	for _, err := range e {
+		if err.Field == "" {
+			err.Field = field
+		} else if strings.HasPrefix(err.Field, "[") {
+			err.Field = field + err.Field
+		} else {
+			err.Field = field + "." + err.Field
+		}
+		if anchor != "" && err.File == "" {
+			err.anchors = append([]string{anchor}, err.anchors...)
+		}
+	}
+	return e
+}
+
+// Anchored prepends anchor to the values used for locating errors, without
+// changing the field of the errors.
+func (e Errors) Anchored(anchor string) Errors {
+	for _, err := range e {
+		if err.File == "" {
+			err.anchors = append([]string{anchor}, err.anchors...)
+		}
+	}
+	return e
+}
+
+// Locate sets the location of all errors without a location, to the file
+// named file, containing data. The line is found by searching data for the
+// values leading up to the error.
+func (e Errors) Locate(file string, data []byte) Errors {
+	lines := strings.Split(string(data), "\n")
+	for _, err := range e {
+		if err.File != "" {
+			continue
+		}
+		err.File = file
+		from := 0
+		for _, anchor := range err.anchors {
+			l := findLine(lines, from, anchor)
+			if l < 0 {
+				break // the closest known location is as good as it gets
+			}
+			from = l
+			err.Line = l + 1
+		}
+	}
+	return e
+}
+
+// findLine returns the index of the first line at or after from, that contains
+// the first line of value. -1 is returned if no such line is found.
+func findLine(lines []string, from int, value string) int {
+	needle := strings.TrimSpace(strings.SplitN(value, "\n", 2)[0])
+	if needle == "" {
+		return -1
+	}
+	for i := from; i < len(lines); i++ {
+		if strings.Contains(lines[i], needle) {
+			return i
+		}
+	}
+	return -1
+}
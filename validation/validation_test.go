@@ -0,0 +1,49 @@
+package validation
+
+import (
+	"fmt"
+	"testing"
+)
+
+const testFile = `http:
+  - name: first
+    conversations:
+      - name: hello
+        request:
+          url-matcher:
+            path: (
+  - name: second
+    conversations:
+      - name: hello
+        request:
+          url-matcher:
+            path: (
+`
+
+func TestErrors_Locate(t *testing.T) {
+	tests := []struct {
+		name     string
+		service  string
+		wantLine int
+		want     string
+	}{
+		{"first", "first", 7, "config.yaml:7: http[first].conversations[hello].request.url-matcher.path: bad"},
+		{"second", "second", 13, "config.yaml:13: http[second].conversations[hello].request.url-matcher.path: bad"},
+		{"unknown", "third", 0, "config.yaml: http[third].conversations[hello].request.url-matcher.path: bad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs Errors
+			errs.Add("request.url-matcher.path", "(", fmt.Errorf("bad"))
+			errs.Within("conversations[hello]", "hello").
+				Within("http["+tt.service+"]", "name: "+tt.service).
+				Locate("config.yaml", []byte(testFile))
+			if errs[0].Line != tt.wantLine {
+				t.Errorf("Locate() line = %d, want %d", errs[0].Line, tt.wantLine)
+			}
+			if got := errs[0].Error(); got != tt.want {
+				t.Errorf("Error() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
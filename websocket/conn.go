@@ -0,0 +1,320 @@
+// Package websocket is a minimal server side implementation of the WebSocket
+// protocol (RFC 6455), sufficient for mocking devices, it does not support any
+// extensions.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Opcodes of data frames
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// Opcodes of control frames
+const (
+	continuationFrame = 0
+	closeFrame        = 8
+	pingFrame         = 9
+	pongFrame         = 10
+)
+
+// Close status codes
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseMessageTooBig = 1009
+	closeNoStatus      = 1005
+)
+
+// DefaultMaxMessageLen is the maximum length of messages read, unless
+// Conn.MaxMessageLen is set.
+const DefaultMaxMessageLen = 1 << 20
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// CloseError is returned by ReadMessage when the peer closed the connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket closed: %d %s", e.Code, e.Reason)
+}
+
+// Conn is a WebSocket connection, ReadMessage must only be called from one
+// goroutine at a time, while WriteMessage and Close may be called concurrently.
+type Conn struct {
+	conn          net.Conn
+	br            *bufio.Reader
+	writeLock     sync.Mutex
+	closed        bool
+	Subprotocol   string // subprotocol selected during the handshake, if any
+	MaxMessageLen int    // messages longer than this are rejected, DefaultMaxMessageLen if 0
+}
+
+// IsUpgrade returns true if r requests an upgrade to WebSocket.
+func IsUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+func headerContains(header http.Header, name string, token string) bool {
+	for _, v := range header.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Upgrade completes the WebSocket handshake of r, selecting the first of
+// subprotocols offered by the client, and takes over the connection. Headers
+// already set on w are sent with the handshake response.
+func Upgrade(w http.ResponseWriter, r *http.Request, subprotocols []string) (*Conn, error) {
+	if r.Method != http.MethodGet || !IsUpgrade(r) {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("unsupported websocket version '%s'", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, fmt.Errorf("missing Sec-WebSocket-Key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket requires a HTTP/1.x connection", http.StatusInternalServerError)
+		return nil, fmt.Errorf("websocket requires a HTTP/1.x connection")
+	}
+
+	c := &Conn{}
+	for _, p := range subprotocols {
+		if headerContains(r.Header, "Sec-WebSocket-Protocol", p) {
+			c.Subprotocol = p
+			break
+		}
+	}
+
+	header := w.Header().Clone()
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header["Sec-WebSocket-Accept"] = []string{acceptKey(key)} // spelled as in RFC 6455, not canonical
+	if c.Subprotocol != "" {
+		header["Sec-WebSocket-Protocol"] = []string{c.Subprotocol}
+	}
+
+	conn, bufrw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	c.br = bufrw.Reader
+	_, _ = bufrw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	_ = header.Write(bufrw)
+	_, _ = bufrw.WriteString("\r\n")
+	if err := bufrw.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// RemoteAddr returns the address of the client.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+type frame struct {
+	fin     bool
+	opcode  int
+	payload []byte
+}
+
+func (c *Conn) readFrame() (frame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return frame{}, err
+	}
+	f := frame{fin: head[0]&0x80 != 0, opcode: int(head[0] & 0x0f)}
+	if head[0]&0x70 != 0 {
+		return f, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return f, c.fail(CloseProtocolError, "client frames must be masked")
+	}
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if f.opcode >= closeFrame && (!f.fin || length > 125) {
+		return f, c.fail(CloseProtocolError, "control frames must not be fragmented or longer than 125 bytes")
+	}
+	if length > uint64(c.maxMessageLen()) {
+		return f, c.fail(CloseMessageTooBig, "message too big")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return f, err
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return f, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+func (c *Conn) maxMessageLen() int {
+	if c.MaxMessageLen > 0 {
+		return c.MaxMessageLen
+	}
+	return DefaultMaxMessageLen
+}
+
+// fail closes the connection with code, and returns an error describing why.
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.Close(code, reason)
+	return fmt.Errorf("websocket protocol error: %s", reason)
+}
+
+// ReadMessage returns the next text or binary message sent by the client. Pings
+// are answered, and fragmented messages are reassembled. When the client closes
+// the connection a *CloseError is returned.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var opcode int
+	var message []byte
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch f.opcode {
+		case pingFrame:
+			if err := c.writeFrame(pongFrame, f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case pongFrame:
+			continue
+		case closeFrame:
+			ce := &CloseError{Code: closeNoStatus}
+			if len(f.payload) >= 2 {
+				ce.Code = int(binary.BigEndian.Uint16(f.payload))
+				ce.Reason = string(f.payload[2:])
+			}
+			_ = c.Close(ce.Code, "")
+			return 0, nil, ce
+		case TextMessage, BinaryMessage:
+			if opcode != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			opcode = f.opcode
+		case continuationFrame:
+			if opcode == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", f.opcode))
+		}
+		message = append(message, f.payload...)
+		if len(message) > c.maxMessageLen() {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		if f.fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+// WriteMessage sends data as a single frame of messageType, TextMessage or
+// BinaryMessage.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	return c.writeFrame(messageType, data)
+}
+
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	head := []byte{0x80 | byte(opcode), 0}
+	switch {
+	case len(payload) < 126:
+		head[1] = byte(len(payload))
+	case len(payload) <= 0xffff:
+		head[1] = 126
+		head = append(head, 0, 0)
+		binary.BigEndian.PutUint16(head[2:], uint16(len(payload)))
+	default:
+		head[1] = 127
+		head = append(head, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(head[2:], uint64(len(payload)))
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.conn.Write(append(head, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Close sends a close frame with code and reason, and closes the connection.
+// Closing an already closed connection does nothing.
+func (c *Conn) Close(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if code == closeNoStatus {
+		payload = nil
+	}
+	err := c.writeFrame(closeFrame, payload)
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if cerr := c.conn.Close(); err == nil && !errors.Is(cerr, net.ErrClosed) {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,305 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// example of RFC 6455, section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey() %s, expected s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", got)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r, []string{"v2", "v1"})
+		if err != nil {
+			return
+		}
+		_ = c.WriteMessage(TextMessage, []byte(c.Subprotocol))
+		_ = c.Close(CloseNormal, "")
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_, _ = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\nConnection: keep-alive, Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Protocol: v1, v2\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("ReadResponse() %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status %d, expected 101", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept %s, expected s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", got)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "v2" {
+		t.Errorf("Sec-WebSocket-Protocol %s, expected v2", got)
+	}
+	if f := readTestFrame(t, br); f.opcode != TextMessage || string(f.payload) != "v2" {
+		t.Errorf("frame %d %q, expected text v2", f.opcode, f.payload)
+	}
+}
+
+func TestUpgradeRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"not upgrade", map[string]string{"Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "a2V5"}, http.StatusUpgradeRequired},
+		{"version", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "8", "Sec-WebSocket-Key": "a2V5"}, http.StatusUpgradeRequired},
+		{"key", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13"}, http.StatusBadRequest},
+		{"not hijackable", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "a2V5"}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			if _, err := Upgrade(w, r, nil); err == nil {
+				t.Errorf("Upgrade() no error")
+			}
+			if w.Code != tt.status {
+				t.Errorf("status %d, expected %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+// testFrame is a frame sent by, or to, the client.
+type testFrame struct {
+	fin      bool
+	opcode   int
+	payload  []byte
+	unmasked bool
+}
+
+func text(fin bool, s string) testFrame {
+	return testFrame{fin: fin, opcode: TextMessage, payload: []byte(s)}
+}
+
+// writeTestFrame writes f as a client frame, masked unless f.unmasked is set.
+func writeTestFrame(w io.Writer, f testFrame) error {
+	head := []byte{byte(f.opcode), 0}
+	if f.fin {
+		head[0] |= 0x80
+	}
+	switch {
+	case len(f.payload) < 126:
+		head[1] = byte(len(f.payload))
+	case len(f.payload) <= 0xffff:
+		head[1] = 126
+		head = append(head, 0, 0)
+		binary.BigEndian.PutUint16(head[2:], uint16(len(f.payload)))
+	default:
+		head[1] = 127
+		head = append(head, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(head[2:], uint64(len(f.payload)))
+	}
+	payload := append([]byte(nil), f.payload...)
+	if !f.unmasked {
+		head[1] |= 0x80
+		mask := []byte{1, 2, 3, 4}
+		head = append(head, mask...)
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	_, err := w.Write(append(head, payload...))
+	return err
+}
+
+// readTestFrame reads a server frame.
+func readTestFrame(t *testing.T, r io.Reader) testFrame {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		t.Fatalf("reading frame %v", err)
+	}
+	if head[1]&0x80 != 0 {
+		t.Fatalf("server frame masked")
+	}
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, _ = io.ReadFull(r, ext[:])
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, _ = io.ReadFull(r, ext[:])
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	f := testFrame{fin: head[0]&0x80 != 0, opcode: int(head[0] & 0x0f), payload: make([]byte, length)}
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		t.Fatalf("reading payload %v", err)
+	}
+	return f
+}
+
+// newTestConn returns a connection as upgraded by the server, and the client
+// end of it.
+func newTestConn(t *testing.T) (*Conn, net.Conn) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() %v", err)
+	}
+	defer func() { _ = l.Close() }()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() %v", err)
+	}
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept() %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+	_ = server.SetDeadline(time.Now().Add(5 * time.Second))
+	return &Conn{conn: server, br: bufio.NewReader(server)}, client
+}
+
+func closeFramePayload(code int, reason string) []byte {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, reason...)
+}
+
+func TestReadMessage(t *testing.T) {
+	long := strings.Repeat("a", 126)
+	longer := strings.Repeat("b", 0x10000)
+	tests := []struct {
+		name      string
+		maxLen    int
+		frames    []testFrame
+		opcode    int
+		message   string
+		closeCode int         // of the close frame sent by the server, 0 if none
+		replies   []testFrame // sent by the server, before any close frame
+	}{
+		{"text", 0, []testFrame{text(true, "hello")}, TextMessage, "hello", 0, nil},
+		{"binary", 0, []testFrame{{fin: true, opcode: BinaryMessage, payload: []byte{0, 1, 2}}}, BinaryMessage, "\x00\x01\x02", 0, nil},
+		{"16-bit length", 0, []testFrame{text(true, long)}, TextMessage, long, 0, nil},
+		{"64-bit length", 0, []testFrame{text(true, longer)}, TextMessage, longer, 0, nil},
+		{"fragmented", 0, []testFrame{
+			text(false, "hel"),
+			{fin: true, opcode: pingFrame, payload: []byte("ping")},
+			{fin: false, opcode: continuationFrame, payload: []byte("l")},
+			{fin: true, opcode: pongFrame},
+			{fin: true, opcode: continuationFrame, payload: []byte("o")},
+		}, TextMessage, "hello", 0, []testFrame{{fin: true, opcode: pongFrame, payload: []byte("ping")}}},
+		{"unmasked", 0, []testFrame{{fin: true, opcode: TextMessage, payload: []byte("a"), unmasked: true}}, 0, "", CloseProtocolError, nil},
+		{"reserved bits", 0, []testFrame{{fin: true, opcode: 0x40 | TextMessage, payload: []byte("a")}}, 0, "", CloseProtocolError, nil},
+		{"unknown opcode", 0, []testFrame{{fin: true, opcode: 3}}, 0, "", CloseProtocolError, nil},
+		{"unexpected continuation", 0, []testFrame{{fin: true, opcode: continuationFrame, payload: []byte("a")}}, 0, "", CloseProtocolError, nil},
+		{"expected continuation", 0, []testFrame{text(false, "a"), text(true, "b")}, 0, "", CloseProtocolError, nil},
+		{"long control frame", 0, []testFrame{{fin: true, opcode: pingFrame, payload: []byte(long)}}, 0, "", CloseProtocolError, nil},
+		{"fragmented control frame", 0, []testFrame{{fin: false, opcode: pingFrame, payload: []byte("a")}}, 0, "", CloseProtocolError, nil},
+		{"max length", 5, []testFrame{text(true, "hello")}, TextMessage, "hello", 0, nil},
+		{"too long", 5, []testFrame{text(true, "hello!")}, 0, "", CloseMessageTooBig, nil},
+		{"too long fragmented", 5, []testFrame{text(false, "hel"), {fin: true, opcode: continuationFrame, payload: []byte("lo!")}}, 0, "", CloseMessageTooBig, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, client := newTestConn(t)
+			c.MaxMessageLen = tt.maxLen
+			go func() {
+				for _, f := range tt.frames {
+					if err := writeTestFrame(client, f); err != nil {
+						return
+					}
+				}
+			}()
+			opcode, message, err := c.ReadMessage()
+			if tt.closeCode == 0 {
+				if err != nil {
+					t.Fatalf("ReadMessage() %v", err)
+				}
+				if opcode != tt.opcode || string(message) != tt.message {
+					t.Errorf("ReadMessage() %d %.20q, expected %d %.20q", opcode, message, tt.opcode, tt.message)
+				}
+			} else if err == nil {
+				t.Fatalf("ReadMessage() %d %.20q, expected error", opcode, message)
+			}
+
+			for _, want := range tt.replies {
+				if f := readTestFrame(t, client); f.opcode != want.opcode || !bytes.Equal(f.payload, want.payload) {
+					t.Errorf("reply %d %q, expected %d %q", f.opcode, f.payload, want.opcode, want.payload)
+				}
+			}
+			if tt.closeCode != 0 {
+				f := readTestFrame(t, client)
+				if f.opcode != closeFrame || len(f.payload) < 2 || int(binary.BigEndian.Uint16(f.payload)) != tt.closeCode {
+					t.Errorf("frame %d %q, expected close %d", f.opcode, f.payload, tt.closeCode)
+				}
+			}
+		})
+	}
+}
+
+func TestReadMessage_Close(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    CloseError
+		echo    []byte
+	}{
+		{"code and reason", closeFramePayload(CloseGoingAway, "bye"), CloseError{Code: CloseGoingAway, Reason: "bye"}, closeFramePayload(CloseGoingAway, "")},
+		{"no status", nil, CloseError{Code: closeNoStatus}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, client := newTestConn(t)
+			go func() { _ = writeTestFrame(client, testFrame{fin: true, opcode: closeFrame, payload: tt.payload}) }()
+			_, _, err := c.ReadMessage()
+			var ce *CloseError
+			if !errors.As(err, &ce) || *ce != tt.want {
+				t.Fatalf("ReadMessage() %v, expected %v", err, &tt.want)
+			}
+			if f := readTestFrame(t, client); f.opcode != closeFrame || !bytes.Equal(f.payload, tt.echo) {
+				t.Errorf("frame %d %q, expected close %q", f.opcode, f.payload, tt.echo)
+			}
+			if err := c.WriteMessage(TextMessage, []byte("a")); !errors.Is(err, net.ErrClosed) {
+				t.Errorf("WriteMessage() after close %v, expected %v", err, net.ErrClosed)
+			}
+			if _, err := client.Read(make([]byte, 1)); err != io.EOF {
+				t.Errorf("Read() after close %v, expected EOF", err)
+			}
+		})
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	for _, n := range []int{0, 125, 126, 0xffff, 0x10000} {
+		c, client := newTestConn(t)
+		payload := bytes.Repeat([]byte{'x'}, n)
+		go func() { _ = c.WriteMessage(BinaryMessage, payload) }()
+		if f := readTestFrame(t, client); !f.fin || f.opcode != BinaryMessage || len(f.payload) != n {
+			t.Errorf("WriteMessage() of %d bytes, read frame fin=%t opcode=%d of %d bytes", n, f.fin, f.opcode, len(f.payload))
+		}
+	}
+}
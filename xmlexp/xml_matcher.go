@@ -0,0 +1,114 @@
+package xmlexp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EqualsPrefix marks a matcher value that is compared literally, instead of
+// being treated as a regular expression.
+const EqualsPrefix = "=="
+
+type pathMatcher struct {
+	path   *Path
+	regexp *regexp.Regexp
+	equals string
+}
+
+func (m pathMatcher) match(v interface{}) bool {
+	s := ValueString(v)
+	if m.regexp != nil {
+		return m.regexp.MatchString(s)
+	}
+	return s == m.equals
+}
+
+// XmlExpr, is a type that can be used to match an XML document against a set of
+// XPath expressions, each mapped to a regexp.Regexp, or a literal value when
+// prefixed by EqualsPrefix. It is created from a map[string]string in the same way
+// as keyvalueexp.KeyValueExpr.
+type XmlExpr struct {
+	matchers []pathMatcher
+}
+
+// Match will decode data and match it against all path-matchers, see MatchDocument.
+func (e *XmlExpr) Match(data []byte) bool {
+	doc, err := Decode(data)
+	if err != nil {
+		return false
+	}
+	return e.MatchDocument(doc)
+}
+
+// MatchDocument will return true if every path-matcher selects at least one
+// value in doc that matches, if not false is returned.
+func (e *XmlExpr) MatchDocument(doc *Node) bool {
+	_, ok := e.Extract(doc)
+	return ok
+}
+
+// Extract returns the first matching value of each path-matcher, keyed by its
+// path, and true if all path-matchers matched. Values of path-matchers that did
+// not match are left out.
+func (e *XmlExpr) Extract(doc *Node) (map[string]string, bool) {
+	values := make(map[string]string)
+	all := true
+	for _, m := range e.matchers {
+		found := false
+		for _, v := range m.path.Select(doc) {
+			if m.match(v) {
+				values[m.path.String()] = ValueString(v)
+				found = true
+				break
+			}
+		}
+		all = all && found
+	}
+	return values, all
+}
+
+// MatcherCount will return the number of path-matchers in the expression.
+func (e *XmlExpr) MatcherCount() int {
+	return len(e.matchers)
+}
+
+// Compile compiles a map of XPath expressions to regular expressions into an
+// *XmlExpr, namespaces maps the prefixes used in the expressions to namespace
+// URIs. Values prefixed by EqualsPrefix are compared literally.
+// ex.
+// e,err := Compile(map[string]string{"//m:PortId": "^\\d+$"}, map[string]string{"m": "urn:example:ports"})
+func Compile(pathValue map[string]string, namespaces map[string]string) (*XmlExpr, error) {
+	paths := make([]string, 0, len(pathValue))
+	for k := range pathValue {
+		paths = append(paths, k)
+	}
+	sort.Strings(paths)
+	e := &XmlExpr{}
+	for _, k := range paths {
+		path, err := CompilePath(k, namespaces)
+		if err != nil {
+			return nil, err
+		}
+		m := pathMatcher{path: path}
+		v := pathValue[k]
+		if strings.HasPrefix(v, EqualsPrefix) {
+			m.equals = strings.TrimPrefix(v, EqualsPrefix)
+		} else if m.regexp, err = regexp.Compile(v); err != nil {
+			return nil, fmt.Errorf("[%s]=%s: %v", k, v, err)
+		}
+		e.matchers = append(e.matchers, m)
+	}
+	return e, nil
+}
+
+// MustCompile this performs the same function as Compile, but it will panic if
+// unable to successfully Compile.
+func MustCompile(pathValue map[string]string, namespaces map[string]string) *XmlExpr {
+	e, err := Compile(pathValue, namespaces)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
@@ -0,0 +1,107 @@
+package xmlexp
+
+import (
+	"reflect"
+	"testing"
+)
+
+const doc = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:p="urn:ports">
+  <soap:Body>
+    <p:SetPort p:force="true" mode="fast">
+      <p:Id>7</p:Id>
+      <p:Admin>up</p:Admin>
+      <Tag>a</Tag>
+      <Tag>b/c</Tag>
+    </p:SetPort>
+  </soap:Body>
+</soap:Envelope>`
+
+var namespaces = map[string]string{
+	"s": "http://schemas.xmlsoap.org/soap/envelope/",
+	"m": "urn:ports",
+	"x": "urn:other",
+}
+
+func TestPath_Select(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{"prefixed", "/s:Envelope/s:Body/m:SetPort/m:Id", []string{"7"}},
+		{"unprefixed-any-namespace", "/Envelope/Body/SetPort/Admin", []string{"up"}},
+		{"wrong-namespace", "/s:Envelope/s:Body/x:SetPort", nil},
+		{"descendant", "//m:Id", []string{"7"}},
+		{"wildcard", "//m:SetPort/*[2]", []string{"up"}},
+		{"position", "//Tag[1]", []string{"a"}},
+		{"last", "//Tag[last()]", []string{"b/c"}},
+		{"attribute", "//m:SetPort/@mode", []string{"fast"}},
+		{"prefixed-attribute", "//SetPort/@m:force", []string{"true"}},
+		{"attribute-predicate", "//SetPort[@mode='fast']/Id", []string{"7"}},
+		{"attribute-predicate_fail", "//SetPort[@mode='slow']/Id", nil},
+		{"child-predicate", `//SetPort[Admin="up"]/Id`, []string{"7"}},
+		{"text-predicate", "//Tag[text()='b/c']", []string{"b/c"}},
+		{"text", "//m:Admin/text()", []string{"up"}},
+		{"string-value", "//Body", []string{"7\n      up\n      a\n      b/c"}},
+	}
+	d, err := Decode([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range MustCompilePath(tt.path, namespaces).Select(d) {
+				got = append(got, ValueString(v))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompilePath_Invalid(t *testing.T) {
+	for _, p := range []string{"Envelope", "/", "/a//", "/y:a", "/a[0]", "/a[b]", "/a[@b='c]", "/@a/b", "/a[@b=c]"} {
+		if _, err := CompilePath(p, namespaces); err == nil {
+			t.Errorf("CompilePath(%q) expected error", p)
+		}
+	}
+}
+
+func TestXmlExpr_Extract(t *testing.T) {
+	tests := []struct {
+		name      string
+		matchers  map[string]string
+		want      map[string]string
+		wantMatch bool
+	}{
+		{"regex", map[string]string{"//m:Id": `^\d+$`}, map[string]string{"//m:Id": "7"}, true},
+		{"equals", map[string]string{"//m:Admin": "==up"}, map[string]string{"//m:Admin": "up"}, true},
+		{"any-of-many", map[string]string{"//Tag": "/"}, map[string]string{"//Tag": "b/c"}, true},
+		{"multi_fail", map[string]string{"//m:Id": "7", "//m:Missing": ".*"}, map[string]string{"//m:Id": "7"}, false},
+	}
+	d, err := Decode([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, match := MustCompile(tt.matchers, namespaces).Extract(d)
+			if !reflect.DeepEqual(got, tt.want) || match != tt.wantMatch {
+				t.Errorf("Extract() = %v, %v, want %v, %v", got, match, tt.want, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestXmlExpr_Match(t *testing.T) {
+	e := MustCompile(map[string]string{"/a/b": "^1$"}, nil)
+	if !e.Match([]byte(`<a><b>1</b></a>`)) {
+		t.Errorf("Match() = false, want true")
+	}
+	if e.Match([]byte(`not xml`)) {
+		t.Errorf("Match() = true on invalid xml, want false")
+	}
+}
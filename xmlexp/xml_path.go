@@ -0,0 +1,436 @@
+package xmlexp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Node is an element of a parsed XML document, names are namespace-aware, so
+// Name.Space holds the namespace URI, not the prefix.
+type Node struct {
+	Name     xml.Name
+	Attr     []xml.Attr
+	Children []*Node
+	text     []string      // character data directly within the node
+	content  []interface{} // character data and children in document order
+}
+
+// Text returns the character data directly within the node.
+func (n *Node) Text() string {
+	return strings.Join(n.text, "")
+}
+
+// Value returns the string-value of the node, all character data within the
+// node and its descendants, with surrounding whitespace trimmed.
+func (n *Node) Value() string {
+	var b strings.Builder
+	n.writeValue(&b)
+	return strings.TrimSpace(b.String())
+}
+
+func (n *Node) writeValue(b *strings.Builder) {
+	for _, c := range n.content {
+		switch t := c.(type) {
+		case string:
+			b.WriteString(t)
+		case *Node:
+			t.writeValue(b)
+		}
+	}
+}
+
+// Decode parses data into a document node, whose only child is the root
+// element of the document.
+func Decode(data []byte) (*Node, error) {
+	doc := &Node{}
+	stack := []*Node{doc}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &Node{Name: t.Name}
+			for _, a := range t.Attr {
+				if a.Name.Space != "xmlns" && a.Name.Local != "xmlns" {
+					n.Attr = append(n.Attr, a)
+				}
+			}
+			top.Children = append(top.Children, n)
+			top.content = append(top.content, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if top != doc {
+				top.text = append(top.text, string(t))
+				top.content = append(top.content, string(t))
+			}
+		}
+	}
+	if len(doc.Children) != 1 {
+		return nil, fmt.Errorf("no root element found")
+	}
+	return doc, nil
+}
+
+// ValueString returns the string representation of a value selected by a
+// Path, the string-value of elements, and the value of attributes and text.
+func ValueString(v interface{}) string {
+	switch t := v.(type) {
+	case *Node:
+		return t.Value()
+	case string:
+		return t
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+type stepKind int
+
+const (
+	elementStep stepKind = iota
+	attributeStep
+	textStep
+)
+
+type nameTest struct {
+	space string // namespace URI, empty matches any namespace
+	local string // "*" matches any name
+}
+
+func (t nameTest) matches(name xml.Name) bool {
+	return (t.local == "*" || t.local == name.Local) && (t.space == "" || t.space == name.Space)
+}
+
+type predicate struct {
+	position int    // 1-based, -1 is last()
+	attr     string // attribute that must be present, or equal value
+	child    string // child element, or text() if ".", that must equal value
+	value    *string
+	name     nameTest
+}
+
+type step struct {
+	kind       stepKind
+	descendant bool // '//', apply to the context node and all its descendants
+	name       nameTest
+	predicates []predicate
+}
+
+// Path is a compiled XPath expression, supporting the subset: absolute location
+// paths of child '/' and descendant '//' steps, name tests with namespace
+// prefixes and '*', attributes '@name', 'text()' and the predicates '[n]',
+// '[last()]', '[@attr]', "[@attr='value']", "[child='value']" and "[text()='value']".
+// Names without prefix match elements in any namespace.
+type Path struct {
+	expr  string
+	steps []step
+}
+
+func (p *Path) String() string {
+	return p.expr
+}
+
+// CompilePath parses an XPath expression into a Path, namespaces maps the
+// prefixes used in the expression to namespace URIs.
+func CompilePath(expr string, namespaces map[string]string) (*Path, error) {
+	s := strings.TrimSpace(expr)
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("path '%s' must start with '/'", expr)
+	}
+	p := &Path{expr: expr}
+	for i := 0; i < len(s); {
+		st := step{}
+		if strings.HasPrefix(s[i:], "//") {
+			st.descendant = true
+			i += 2
+		} else if s[i] == '/' {
+			i++
+		} else {
+			return nil, fmt.Errorf("path '%s': unexpected '%s' at %d", expr, s[i:], i)
+		}
+		end := stepEnd(s, i)
+		if err := st.parse(s[i:end], namespaces); err != nil {
+			return nil, fmt.Errorf("path '%s': %v", expr, err)
+		}
+		if len(p.steps) > 0 && p.steps[len(p.steps)-1].kind != elementStep {
+			return nil, fmt.Errorf("path '%s': no steps allowed after attribute or text()", expr)
+		}
+		p.steps = append(p.steps, st)
+		i = end
+	}
+	return p, nil
+}
+
+// MustCompilePath is like CompilePath, but panics if the expression cannot be
+// parsed.
+func MustCompilePath(expr string, namespaces map[string]string) *Path {
+	p, err := CompilePath(expr, namespaces)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// stepEnd returns the index of the '/' ending the step starting at i, ignoring
+// any '/' within predicates.
+func stepEnd(s string, i int) int {
+	depth := 0
+	var quote byte
+	for ; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '/' && depth == 0:
+			return i
+		}
+	}
+	return i
+}
+
+func (st *step) parse(s string, namespaces map[string]string) error {
+	if s == "" {
+		return fmt.Errorf("empty step")
+	}
+	if s == "text()" {
+		st.kind = textStep
+		return nil
+	}
+	name := s
+	if b := strings.IndexByte(s, '['); b >= 0 {
+		name = s[:b]
+		preds, err := parsePredicates(s[b:], namespaces)
+		if err != nil {
+			return err
+		}
+		st.predicates = preds
+	}
+	if strings.HasPrefix(name, "@") {
+		if len(st.predicates) > 0 {
+			return fmt.Errorf("predicates not allowed on attribute '%s'", s)
+		}
+		st.kind = attributeStep
+		name = name[1:]
+	}
+	test, err := parseNameTest(name, namespaces)
+	if err != nil {
+		return err
+	}
+	st.name = test
+	return nil
+}
+
+func parseNameTest(s string, namespaces map[string]string) (nameTest, error) {
+	if s == "" {
+		return nameTest{}, fmt.Errorf("missing name")
+	}
+	t := strings.SplitN(s, ":", 2)
+	if len(t) == 1 {
+		return nameTest{local: s}, nil
+	}
+	uri, found := namespaces[t[0]]
+	if !found {
+		return nameTest{}, fmt.Errorf("unknown namespace prefix '%s'", t[0])
+	}
+	return nameTest{space: uri, local: t[1]}, nil
+}
+
+func parsePredicates(s string, namespaces map[string]string) ([]predicate, error) {
+	var preds []predicate
+	for len(s) > 0 {
+		if s[0] != '[' {
+			return nil, fmt.Errorf("unexpected '%s'", s)
+		}
+		closing := -1
+		var quote byte
+		for i := 1; i < len(s) && closing < 0; i++ {
+			switch c := s[i]; {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '\'' || c == '"':
+				quote = c
+			case c == ']':
+				closing = i
+			}
+		}
+		if closing < 0 {
+			return nil, fmt.Errorf("unterminated predicate '%s'", s)
+		}
+		pred, err := parsePredicate(strings.TrimSpace(s[1:closing]), namespaces)
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, pred)
+		s = s[closing+1:]
+	}
+	return preds, nil
+}
+
+func parsePredicate(s string, namespaces map[string]string) (predicate, error) {
+	if s == "last()" {
+		return predicate{position: -1}, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 {
+			return predicate{}, fmt.Errorf("invalid position %d", n)
+		}
+		return predicate{position: n}, nil
+	}
+	left, value := s, (*string)(nil)
+	if eq := strings.IndexByte(s, '='); eq >= 0 {
+		left = strings.TrimSpace(s[:eq])
+		literal := strings.TrimSpace(s[eq+1:])
+		if len(literal) < 2 || (literal[0] != '\'' && literal[0] != '"') || literal[len(literal)-1] != literal[0] {
+			return predicate{}, fmt.Errorf("invalid literal '%s'", literal)
+		}
+		v := literal[1 : len(literal)-1]
+		value = &v
+	}
+	pred := predicate{value: value}
+	switch {
+	case left == "text()":
+		if value == nil {
+			return predicate{}, fmt.Errorf("text() predicate requires a value")
+		}
+		pred.child = "."
+		return pred, nil
+	case strings.HasPrefix(left, "@"):
+		pred.attr = left[1:]
+	default:
+		if value == nil {
+			return predicate{}, fmt.Errorf("child predicate '%s' requires a value", left)
+		}
+		pred.child = left
+	}
+	test, err := parseNameTest(strings.TrimPrefix(left, "@"), namespaces)
+	if err != nil {
+		return predicate{}, err
+	}
+	pred.name = test
+	return pred, nil
+}
+
+func (p predicate) matches(n *Node, position, size int) bool {
+	switch {
+	case p.position == -1:
+		return position == size
+	case p.position > 0:
+		return position == p.position
+	case p.attr != "":
+		for _, a := range n.Attr {
+			if p.name.matches(a.Name) {
+				return p.value == nil || a.Value == *p.value
+			}
+		}
+		return false
+	case p.child == ".":
+		return strings.TrimSpace(n.Text()) == *p.value
+	default:
+		for _, c := range n.Children {
+			if p.name.matches(c.Name) && c.Value() == *p.value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Select returns all values in doc selected by the path, elements as *Node
+// and attributes and text as string.
+func (p *Path) Select(doc *Node) []interface{} {
+	nodes := []*Node{doc}
+	for _, st := range p.steps {
+		var context []*Node
+		for _, n := range nodes {
+			if st.descendant {
+				context = append(context, descendants(n)...)
+			} else {
+				context = append(context, n)
+			}
+		}
+		switch st.kind {
+		case attributeStep:
+			var values []interface{}
+			for _, n := range context {
+				for _, a := range n.Attr {
+					if st.name.matches(a.Name) {
+						values = append(values, a.Value)
+					}
+				}
+			}
+			return values
+		case textStep:
+			var values []interface{}
+			for _, n := range context {
+				for _, t := range n.text {
+					if strings.TrimSpace(t) != "" {
+						values = append(values, t)
+					}
+				}
+			}
+			return values
+		}
+		var next []*Node
+		for _, n := range context {
+			next = append(next, st.children(n)...)
+		}
+		nodes = next
+	}
+	values := make([]interface{}, 0, len(nodes))
+	for _, n := range nodes {
+		values = append(values, n)
+	}
+	return values
+}
+
+// children returns the child elements of n matching the name test, and all
+// predicates of the step.
+func (st step) children(n *Node) []*Node {
+	var matched []*Node
+	for _, c := range n.Children {
+		if st.name.matches(c.Name) {
+			matched = append(matched, c)
+		}
+	}
+	for _, pred := range st.predicates {
+		var filtered []*Node
+		for i, c := range matched {
+			if pred.matches(c, i+1, len(matched)) {
+				filtered = append(filtered, c)
+			}
+		}
+		matched = filtered
+	}
+	return matched
+}
+
+// descendants returns n and all elements below it, depth first.
+func descendants(n *Node) []*Node {
+	all := []*Node{n}
+	for _, c := range n.Children {
+		all = append(all, descendants(c)...)
+	}
+	return all
+}